@@ -2,14 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/seyedmo30/order_management/internal/config"
 	"github.com/seyedmo30/order_management/internal/delivery/http"
 	"github.com/seyedmo30/order_management/internal/process"
 	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/sink"
 	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -24,27 +34,77 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 func main() {
 	// Load configuration
 	cfg := config.Load()
-	ctx := context.Background()
+
+	// Shut down on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Initialize repository
-	repo := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	repo, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	if err != nil {
+		log.Fatalf("Failed to set up the repository: %v", err)
+	}
 
 	process := process.NewProcessUseCase(cfg)
 
-	usecase := usecase.NewOrderUseCase(cfg, repo, process)
-
-	// Start the order processing in a background worker (goroutine)
+	webhookUsecase := usecase.NewWebhookUseCase(cfg, repo)
+
+	sinks, err := sink.FromConfig(cfg, repo)
+	if err != nil {
+		log.Fatalf("Failed to configure outbox sinks: %v", err)
+	}
+	outboxRelay := usecase.NewOutboxRelay(cfg, repo, sinks...)
+
+	orderUsecase := usecase.NewOrderUseCase(cfg, repo, process)
+
+	// A component that fails for good reports it and shuts the service down
+	var failed atomic.Bool
+	fail := func(format string, args ...interface{}) {
+		log.Printf(format, args...)
+		failed.Store(true)
+		stop()
+	}
+
+	// Start the order processing in background workers (goroutines); each of
+	// them returns once ctx is done
+	var wg sync.WaitGroup
+	wg.Add(4)
+	if cfg.AutoscaleEnabled {
+		autoscaler := usecase.NewAutoscaler(cfg, repo, orderUsecase, pkg.SystemClock)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := autoscaler.AutoscaleWorkers(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Error in AutoscaleWorkers: %v", err)
+			}
+		}()
+	}
 	go func() {
-
-		if err := usecase.ListAggregateOrderReport(ctx); err != nil {
+		defer wg.Done()
+		if err := orderUsecase.ListAggregateOrderReport(ctx); err != nil && !errors.Is(err, context.Canceled) {
 			log.Printf("Error in ListAggregateOrderReport: %v", err)
 		}
 
 	}()
 	go func() {
+		defer wg.Done()
+		if err := orderUsecase.ProcessOrder(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			fail("Error while processing orders: %v", err)
+		}
+
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := outboxRelay.RelayOutbox(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Error in RelayOutbox: %v", err)
+		}
 
-		if err := usecase.ProcessOrder(ctx); err != nil {
-			log.Fatalf("Error while processing orders: %v", err)
+	}()
+	go func() {
+		defer wg.Done()
+		if err := webhookUsecase.DispatchWebhooks(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Error in DispatchWebhooks: %v", err)
 		}
 
 	}()
@@ -52,12 +112,45 @@ func main() {
 	// Set up Echo instance
 	e := echo.New()
 	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HTTPErrorHandler = http.ErrorHandler
 
 	// Initialize handlers
-	orderHandler := http.NewOrderHandler(usecase)
+	orderHandler := http.NewOrderHandler(orderUsecase)
+	webhookHandler := http.NewWebhookHandler(webhookUsecase)
+
+	// Event streams and long-polls end at shutdown instead of holding it up,
+	// while the other requests in flight finish
+	e.Server.RegisterOnShutdown(orderHandler.Shutdown)
 
 	// Register routes
-	http.RegisterRoutes(e, orderHandler)
+	http.RegisterRoutes(e, orderHandler, webhookHandler)
 	// Start the server
-	log.Fatal(e.Start(":8099"))
+	go func() {
+		if err := e.Start(":8099"); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			fail("Error while serving HTTP: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down")
+
+	// Stop accepting requests and let the ones in flight complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error while shutting down HTTP server: %v", err)
+		e.Close()
+	}
+
+	// Wait for the workers to drain the orders in flight
+	wg.Wait()
+
+	if err := repository.Close(); err != nil {
+		log.Printf("Error while closing database: %v", err)
+	}
+	log.Printf("Shutdown complete")
+	if failed.Load() {
+		os.Exit(1)
+	}
 }
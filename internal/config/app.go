@@ -4,9 +4,11 @@ Package config contains the configuration for the application.
 package config
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/seyedmo30/order_management/pkg"
 )
 
 // App holds the application configuration.
@@ -16,8 +18,17 @@ type App struct {
 }
 
 // DatabaseConfig contains the database connection details.
+// DSN accepts either a plain file path or a "file:" URI; use
+// "file::memory:?cache=shared&mode=rw" for a throwaway in-memory database.
+// An in-memory database always uses a single pooled connection, whatever
+// MaxOpenConns and MaxIdleConns say.
 type DatabaseConfig struct {
-	LogLevel string `env:"DB_LOG_LEVEL" envDefault:"INFO"`
+	LogLevel     string `env:"DB_LOG_LEVEL" envDefault:"INFO"`
+	DSN          string `env:"DB_DSN" envDefault:"file:order_management.db"`
+	BusyTimeout  int    `env:"DB_BUSY_TIMEOUT" envDefault:"5000"` // milliseconds
+	Synchronous  string `env:"DB_SYNCHRONOUS" envDefault:"NORMAL"`
+	MaxOpenConns int    `env:"DB_MAX_OPEN_CONNS" envDefault:"10"`
+	MaxIdleConns int    `env:"DB_MAX_IDLE_CONNS" envDefault:"5"`
 }
 
 // ServiceConfig contains application-specific settings.
@@ -25,6 +36,77 @@ type ServiceConfig struct {
 	ReportInterval      int `env:"SERVICE_REPORT_INTERVAL" envDefault:"2"`
 	WorkerCount         int `env:"SERVICE_WORKER_COUNT" envDefault:"5"`
 	OrderProcessTimeout int `env:"SERVICE_ORDER_PROCESS_TIMEOUT" envDefault:"5"`
+
+	// InstanceID identifies this process in order lock owners; defaults to the hostname.
+	InstanceID          string `env:"SERVICE_INSTANCE_ID"`
+	LeaseDuration       int    `env:"SERVICE_LEASE_DURATION" envDefault:"30"`
+	LeaseReaperInterval int    `env:"SERVICE_LEASE_REAPER_INTERVAL" envDefault:"10"`
+
+	// An idle worker is woken when orders are queued in this process, and also
+	// looks for claimable orders every WorkerPollInterval seconds, which picks up
+	// retries that became due and orders queued by other processes.
+	WorkerPollInterval int `env:"SERVICE_WORKER_POLL_INTERVAL" envDefault:"1"`
+
+	// WorkerReservations starts workers, besides the WorkerCount shared ones,
+	// that only claim orders of a priority or above, such as "High=2,Critical=1".
+	WorkerReservations map[string]int `env:"SERVICE_WORKER_RESERVATIONS" envKeyValSeparator:"="`
+
+	// On shutdown the workers stop claiming orders and the orders they are
+	// processing get ShutdownTimeout seconds to finish; unfinished ones are
+	// returned to the queue. HTTP requests in flight get as long to complete.
+	ShutdownTimeout int `env:"SERVICE_SHUTDOWN_TIMEOUT" envDefault:"30"`
+
+	// With AutoscaleEnabled the worker pool is resized every AutoscaleInterval
+	// seconds, between AutoscaleMinWorkers and AutoscaleMaxWorkers, to drain the
+	// pending orders within an interval. It shrinks when more than
+	// AutoscaleMaxContention percent of the claims hit database contention.
+	AutoscaleEnabled       bool `env:"SERVICE_AUTOSCALE_ENABLED" envDefault:"false"`
+	AutoscaleMinWorkers    int  `env:"SERVICE_AUTOSCALE_MIN_WORKERS" envDefault:"1"`
+	AutoscaleMaxWorkers    int  `env:"SERVICE_AUTOSCALE_MAX_WORKERS" envDefault:"20"`
+	AutoscaleInterval      int  `env:"SERVICE_AUTOSCALE_INTERVAL" envDefault:"10"`
+	AutoscaleMaxContention int  `env:"SERVICE_AUTOSCALE_MAX_CONTENTION" envDefault:"10"`
+
+	// A waiting order gains PriorityAgingStep levels every PriorityAgingInterval
+	// seconds; an interval of 0 disables aging.
+	PriorityAgingInterval int `env:"SERVICE_PRIORITY_AGING_INTERVAL" envDefault:"10"`
+	PriorityAgingStep     int `env:"SERVICE_PRIORITY_AGING_STEP" envDefault:"1"`
+
+	// A failed order is retried until it has been attempted MaxAttempts times,
+	// waiting RetryBackoffBase seconds doubled per attempt, up to RetryBackoffMax.
+	MaxAttempts      int `env:"SERVICE_MAX_ATTEMPTS" envDefault:"3"`
+	RetryBackoffBase int `env:"SERVICE_RETRY_BACKOFF_BASE" envDefault:"2"`
+	RetryBackoffMax  int `env:"SERVICE_RETRY_BACKOFF_MAX" envDefault:"60"`
+
+	// DeadLetterEnabled moves orders without attempts left to DeadLettered
+	// instead of marking them Failed.
+	DeadLetterEnabled bool `env:"SERVICE_DEAD_LETTER_ENABLED" envDefault:"true"`
+
+	// Event streams are woken by this process's order changes, and also look for
+	// changes made by other processes every EventStreamPollInterval seconds.
+	EventStreamPollInterval int `env:"SERVICE_EVENT_STREAM_POLL_INTERVAL" envDefault:"5"`
+
+	// An idempotency key replays the response of the order it created for
+	// IdempotencyKeyTTL hours, after which it may be used again.
+	IdempotencyKeyTTL int `env:"SERVICE_IDEMPOTENCY_KEY_TTL" envDefault:"24"`
+
+	// Due webhook deliveries are sent every WebhookPollInterval seconds, waiting
+	// WebhookTimeout seconds for the receiver. A failed delivery is retried until
+	// it has been attempted WebhookMaxAttempts times, waiting WebhookBackoffBase
+	// seconds doubled per attempt, up to WebhookBackoffMax.
+	WebhookPollInterval int `env:"SERVICE_WEBHOOK_POLL_INTERVAL" envDefault:"1"`
+	WebhookTimeout      int `env:"SERVICE_WEBHOOK_TIMEOUT" envDefault:"10"`
+	WebhookMaxAttempts  int `env:"SERVICE_WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	WebhookBackoffBase  int `env:"SERVICE_WEBHOOK_BACKOFF_BASE" envDefault:"2"`
+	WebhookBackoffMax   int `env:"SERVICE_WEBHOOK_BACKOFF_MAX" envDefault:"300"`
+
+	// The outbox relay publishes outbox messages to OutboxSinks (a comma separated
+	// list of log, file, webhook and memory) every OutboxPollInterval seconds. The
+	// file sink appends to OutboxFilePath. Published messages are deleted after
+	// OutboxRetention hours; 0 keeps them.
+	OutboxSinks        string `env:"SERVICE_OUTBOX_SINKS" envDefault:"webhook"`
+	OutboxFilePath     string `env:"SERVICE_OUTBOX_FILE_PATH" envDefault:"order_events.jsonl"`
+	OutboxPollInterval int    `env:"SERVICE_OUTBOX_POLL_INTERVAL" envDefault:"1"`
+	OutboxRetention    int    `env:"SERVICE_OUTBOX_RETENTION" envDefault:"24"`
 }
 
 // Load initializes and loads the configuration from environment variables.
@@ -36,5 +118,22 @@ func Load() App {
 		log.Fatalf("Error loading environment variables: %v", err)
 	}
 
+	if err := cfg.validateWorkerReservations(); err != nil {
+		log.Fatalf("Error loading environment variables: %v", err)
+	}
+
 	return cfg
 }
+
+// validateWorkerReservations checks that workers are reserved for known priorities.
+func (cfg App) validateWorkerReservations() error {
+	for priority, workers := range cfg.WorkerReservations {
+		if _, ok := pkg.PriorityLevel(priority); !ok {
+			return fmt.Errorf("SERVICE_WORKER_RESERVATIONS: unknown priority %q", priority)
+		}
+		if workers < 0 {
+			return fmt.Errorf("SERVICE_WORKER_RESERVATIONS: negative worker count for %s", priority)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+var logger = pkg.GetLogger()
+
+// problemContentType is the media type of error responses (RFC 9457).
+const problemContentType = "application/problem+json"
+
+// problem is an error response in the problem details format. Code is the
+// stable, machine-readable kind of the error, also part of Type.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// ErrorHandler renders the errors returned by handlers as problem details, with
+// the status of their error kind. Errors of no known kind are logged and reported
+// as internal errors without their details.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	p := problemOf(err)
+	p.Type = "urn:order-management:problem:" + p.Code
+	p.Instance = c.Request().URL.Path
+	if p.Status >= http.StatusInternalServerError {
+		logger.Error("Request failed", "method", c.Request().Method, "path", p.Instance, "error", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, problemContentType)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		err = c.JSON(p.Status, p)
+	}
+	if err != nil {
+		logger.Error("Failed to send error response", "path", p.Instance, "error", err)
+	}
+}
+
+// problemOf describes an error as problem details.
+func problemOf(err error) problem {
+	var customErr *pkg.ErrorCustom
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &customErr):
+		p := problem{Title: customErr.Message, Status: customErr.Code, Detail: customErr.Description, Code: customErr.Type}
+		var transitionErr *pkg.StateTransitionError
+		if errors.As(err, &transitionErr) {
+			p.Detail = transitionErr.Error()
+		}
+		if p.Code == "" {
+			p.Code = statusCode(p.Status)
+		}
+		return p
+	case errors.As(err, &httpErr):
+		// Raised by Echo itself, for example for an unknown route
+		p := problem{Title: http.StatusText(httpErr.Code), Status: httpErr.Code, Code: statusCode(httpErr.Code)}
+		if detail := fmt.Sprint(httpErr.Message); detail != p.Title {
+			p.Detail = detail
+		}
+		return p
+	default:
+		return problem{Title: pkg.ErrInternal.Message, Status: pkg.ErrInternal.Code, Code: pkg.ErrInternal.Type}
+	}
+}
+
+// statusCode names the error kind of a bare HTTP status, such as method_not_allowed.
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// invalidRequest reports a request that could not be bound or failed validation.
+func invalidRequest(err error) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return pkg.ErrInvalidRequest.WithDescription(fmt.Sprint(httpErr.Message))
+	}
+	return pkg.ErrInvalidRequest.WithDescription(err.Error())
+}
@@ -1,58 +1,536 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/seyedmo30/order_management/internal/dto"
 	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/pkg"
 )
 
 type OrderHandler struct {
-	usecase interfaces.OrderUseCase
+	usecase      interfaces.OrderUseCase
+	shutdown     context.Context    // Done once the server shuts down
+	stopRequests context.CancelFunc // Ends the event streams and cuts the waits short
 }
 
 func NewOrderHandler(usecase interfaces.OrderUseCase) *OrderHandler {
-	return &OrderHandler{usecase: usecase}
+	shutdown, stopRequests := context.WithCancel(context.Background())
+	return &OrderHandler{usecase: usecase, shutdown: shutdown, stopRequests: stopRequests}
+}
+
+// Shutdown ends the event streams and cuts the waits short, so that they do not
+// hold up the shutdown of the server, while the other requests in flight finish.
+// It is registered with http.Server.RegisterOnShutdown.
+func (h *OrderHandler) Shutdown() {
+	h.stopRequests()
+}
+
+// untilShutdown returns the context of a request that waits, also cancelled when
+// the server shuts down.
+func (h *OrderHandler) untilShutdown(c echo.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	stop := context.AfterFunc(h.shutdown, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// cutShort reports whether err only tells that the wait of a request was cut
+// short by the shutdown of the server.
+func (h *OrderHandler) cutShort(c echo.Context, err error) bool {
+	return errors.Is(err, context.Canceled) && h.shutdown.Err() != nil && c.Request().Context().Err() == nil
 }
 
 func (h *OrderHandler) CreateOrder(c echo.Context) error {
 	var req dto.CreateOrderHttpHandlerRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request"})
+		return invalidRequest(err)
 	}
 
 	if err := c.Validate(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"status": "Failed", "error": err.Error()})
+		return invalidRequest(err)
+	}
+
+	idempotencyKey := c.Request().Header.Get(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return pkg.ErrInvalidRequest.WithDescription(fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength))
+	}
+
+	// The response is stored with the idempotency key, so it is built up front
+	body, err := json.Marshal(echo.Map{"message": "order created successfully"})
+	if err != nil {
+		return err
 	}
-	err := h.usecase.CreateOrder(c.Request().Context(), dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: req.BaseCreateOrderRequest})
+
+	res, err := h.usecase.CreateOrder(c.Request().Context(), dto.CreateOrderUsecaseRequest{
+		BaseCreateOrderRequest: req.BaseCreateOrderRequest,
+		Actor:                  apiActor(c),
+		IdempotencyKey:         idempotencyKey,
+		Response:               dto.IdempotentResponse{Code: http.StatusAccepted, Body: body},
+	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return err
 	}
 
-	return c.JSON(http.StatusAccepted, echo.Map{"message": "order created successfully"})
+	if res.Replayed {
+		c.Response().Header().Set(idempotentReplayedHeader, "true")
+	}
+	return c.JSONBlob(res.Response.Code, res.Response.Body)
 }
 
+// maxBatchOrders bounds the orders of a batch submission.
+const maxBatchOrders = 1000
+
+// CreateOrders queues a batch of orders given as a JSON array, or as NDJSON with
+// one order per line. Every order is validated on its own; the response reports
+// for each whether it was created, and why not.
+func (h *OrderHandler) CreateOrders(c echo.Context) error {
+	items, err := readBatch(c)
+	if err != nil {
+		return invalidRequest(err)
+	}
+	if len(items) == 0 {
+		return pkg.ErrInvalidRequest.WithDescription("the batch has no orders")
+	}
+	if len(items) > maxBatchOrders {
+		return pkg.ErrBatchTooLarge.WithDescription(fmt.Sprintf("a batch may have at most %d orders", maxBatchOrders))
+	}
+
+	results := make([]dto.CreateOrderResult, len(items))
+	var orders []dto.BaseCreateOrderRequest
+	var indexes []int // Index in the batch of every order in orders
+	for i, item := range items {
+		var req dto.CreateOrderHttpHandlerRequest
+		if err := json.Unmarshal(item, &req); err != nil {
+			results[i] = dto.CreateOrderResult{Status: pkg.StatusBatchItemInvalid, Error: "invalid order: " + err.Error()}
+			continue
+		}
+		if err := c.Validate(&req); err != nil {
+			results[i] = dto.CreateOrderResult{OrderID: req.OrderID, Status: pkg.StatusBatchItemInvalid, Error: err.Error()}
+			continue
+		}
+		orders = append(orders, req.BaseCreateOrderRequest)
+		indexes = append(indexes, i)
+	}
+
+	if len(orders) > 0 {
+		res, err := h.usecase.CreateOrders(c.Request().Context(), dto.CreateOrdersUsecaseRequest{Orders: orders, Actor: apiActor(c)})
+		if err != nil {
+			return err
+		}
+		for i, result := range res.Results {
+			results[indexes[i]] = result
+		}
+	}
+
+	created := 0
+	views := make([]echo.Map, 0, len(results))
+	for i, result := range results {
+		if result.Status == pkg.StatusBatchItemCreated {
+			created++
+		}
+		view := echo.Map{"index": i, "order_id": result.OrderID, "status": result.Status}
+		if result.Error != "" {
+			view["error"] = result.Error
+		}
+		views = append(views, view)
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"created":  created,
+		"rejected": len(results) - created,
+		"results":  views,
+	})
+}
+
+// readBatch splits a batch submission into its orders, reading one more than
+// maxBatchOrders at most.
+func readBatch(c echo.Context) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	body := c.Request().Body
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType == "application/x-ndjson" || mediaType == "application/ndjson" {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() && len(items) <= maxBatchOrders {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				items = append(items, json.RawMessage(bytes.Clone(line)))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("invalid NDJSON batch: %w", err)
+		}
+		return items, nil
+	}
+
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("a batch must be a JSON array of orders, or NDJSON")
+	}
+	for decoder.More() && len(items) <= maxBatchOrders {
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("invalid JSON batch: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Retries of an order creation carrying the same Idempotency-Key get the original
+// response, marked by the Idempotent-Replayed header.
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
 func (h *OrderHandler) GetOrders(c echo.Context) error {
 	// Retrieve the order ID from the URL parameter
 	orderID := c.Param("order_id")
 	if orderID == "" {
 		// Handle case where order_id is missing
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "order_id is required"})
+		return pkg.ErrInvalidRequest.WithDescription("order_id is required")
+	}
+
+	// With ?wait=, block until the processing of the order ends or the wait elapses
+	if wait := c.QueryParam("wait"); wait != "" {
+		return h.waitOrder(c, orderID, wait)
 	}
 
 	// Call the use case to fetch the order details
 	order, err := h.usecase.GetOrder(c.Request().Context(), orderID)
 	if err != nil {
-		// Handle errors (e.g., order not found, internal error)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return err
 	}
 
 	// Return the order details in the response
-	return c.JSON(http.StatusOK, echo.Map{
-		"order_id":        order.OrderID,
-		"priority":        order.Priority,
-		"processing_time": order.ProcessingTime,
-		"status":          order.Status,
+	return c.JSON(http.StatusOK, orderView(order))
+}
+
+// maxOrderWait bounds how long a lookup may wait for an order.
+const maxOrderWait = 60 * time.Second
+
+// waitOrder answers an order lookup with ?wait=: 200 with the final order once its
+// processing has ended, or 202 with the order as it is when the wait elapses.
+// The wait is a duration such as 30s, or a number of seconds.
+func (h *OrderHandler) waitOrder(c echo.Context, orderID string, wait string) error {
+	duration, err := parseWait(wait, maxOrderWait)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := h.untilShutdown(c)
+	defer cancel()
+	res, err := h.usecase.WaitOrder(ctx, dto.WaitOrderUsecaseRequest{OrderID: orderID, Wait: duration})
+	if h.cutShort(c, err) {
+		// Answer with the order as it is, as if the wait had elapsed
+		res, err = h.usecase.WaitOrder(c.Request().Context(), dto.WaitOrderUsecaseRequest{OrderID: orderID})
+	}
+	if requestGone(c, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !res.Ended {
+		return c.JSON(http.StatusAccepted, orderView(res.GetOrderUsecaseResponse))
+	}
+	return c.JSON(http.StatusOK, orderView(res.GetOrderUsecaseResponse))
+}
+
+// requestGone reports whether err only tells that the request ended while it was
+// waiting, because the client disconnected or the server is shutting down. Such
+// requests are not answered with an error.
+func requestGone(c echo.Context, err error) bool {
+	return errors.Is(err, context.Canceled) && c.Request().Context().Err() != nil
+}
+
+// parseWait parses a wait given as a duration such as 30s, or a number of seconds,
+// of at most maxWait.
+func parseWait(wait string, maxWait time.Duration) (time.Duration, error) {
+	duration, err := time.ParseDuration(wait)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(wait)
+		if convErr != nil {
+			return 0, pkg.ErrInvalidRequest.WithDescription("invalid wait duration")
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if duration < 0 || duration > maxWait {
+		return 0, pkg.ErrInvalidRequest.WithDescription(fmt.Sprintf("wait must be between 0s and %s", maxWait))
+	}
+	return duration, nil
+}
+
+func (h *OrderHandler) GetOrderHistory(c echo.Context) error {
+	orderID := c.Param("order_id")
+	if orderID == "" {
+		return pkg.ErrInvalidRequest.WithDescription("order_id is required")
+	}
+
+	res, err := h.usecase.GetOrderHistory(c.Request().Context(), orderID)
+	if err != nil {
+		return err
+	}
+
+	events := make([]echo.Map, 0, len(res.Events))
+	for _, event := range res.Events {
+		events = append(events, orderEventView(event))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"order_id": orderID, "history": events})
+}
+
+// eventStreamKeepAlive is how often an idle event stream sends a comment, so that
+// proxies do not close the connection.
+const eventStreamKeepAlive = 15 * time.Second
+
+// StreamOrderEvents streams order status changes as Server-Sent Events, of every
+// order or of the order in the path. Every event carries its sequence number as
+// its ID, so a reconnecting client resumes after the last event it received by
+// sending it as Last-Event-ID (or as the last_event_id query parameter).
+func (h *OrderHandler) StreamOrderEvents(c echo.Context) error {
+	req := dto.StreamOrderEventsUsecaseRequest{OrderID: c.Param("order_id")}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return pkg.ErrInvalidRequest.WithDescription("invalid Last-Event-ID")
+		}
+		afterID := uint(id)
+		req.AfterID = &afterID
+	}
+
+	// The stream ends when the client disconnects or the server shuts down
+	ctx, cancel := h.untilShutdown(c)
+	defer cancel()
+	events, err := h.usecase.StreamOrderEvents(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			// The stream ends when the client disconnects
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(orderEventView(event))
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: order_status\ndata: %s\n\n", event.ID, data); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+// orderEventView renders an order event for the API.
+func orderEventView(event dto.BaseOrderEvent) echo.Map {
+	return echo.Map{
+		"id":          event.ID,
+		"order_id":    event.OrderID,
+		"actor":       event.Actor,
+		"from_status": event.FromStatus,
+		"to_status":   event.ToStatus,
+		"reason":      event.Reason,
+		"created_at":  event.CreatedAt,
+	}
+}
+
+func (h *OrderHandler) ListOrders(c echo.Context) error {
+	var req dto.ListOrdersHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+	req.Statuses = splitQueryValues(req.Statuses)
+	req.Priorities = splitQueryValues(req.Priorities)
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.ListOrders(c.Request().Context(), dto.ListOrdersUsecaseRequest{
+		Statuses:      req.Statuses,
+		Priorities:    req.Priorities,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		UpdatedAfter:  req.UpdatedAfter,
+		UpdatedBefore: req.UpdatedBefore,
+		Locked:        req.Locked,
+		SortBy:        req.SortBy,
+		Order:         req.Order,
+		Cursor:        req.Cursor,
+		Limit:         req.Limit,
 	})
+	if err != nil {
+		return err
+	}
+
+	orders := make([]echo.Map, 0, len(res.Orders))
+	for _, order := range res.Orders {
+		orders = append(orders, orderView(order))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"orders": orders, "next_cursor": res.NextCursor})
+}
+
+// orderView renders an order for the API.
+func orderView(order dto.GetOrderUsecaseResponse) echo.Map {
+	return echo.Map{
+		"order_id":           order.OrderID,
+		"priority":           order.PriorityName,
+		"effective_priority": order.EffectivePriority,
+		"processing_time":    order.ProcessingTime,
+		"status":             order.Status,
+		"cancel_reason":      order.CancelReason,
+		"attempts":           order.Attempts,
+		"next_attempt_at":    order.NextAttemptAt,
+		"locked":             order.LockOwner != nil,
+		"created_at":         order.CreatedAt,
+		"updated_at":         order.UpdatedAt,
+	}
+}
+
+// apiActor identifies the caller of a request in the order history: the client IP,
+// prefixed by the X-Actor header when the caller names itself.
+func apiActor(c echo.Context) string {
+	if actor := c.Request().Header.Get("X-Actor"); actor != "" {
+		return "api:" + actor + "@" + c.RealIP()
+	}
+	return "api:" + c.RealIP()
+}
+
+// splitQueryValues splits comma separated query values, so that a filter can be
+// given as ?status=Pending,Failed as well as ?status=Pending&status=Failed.
+func splitQueryValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				split = append(split, part)
+			}
+		}
+	}
+	return split
+}
+
+func (h *OrderHandler) CancelOrder(c echo.Context) error {
+	var req dto.CancelOrderHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	err := h.usecase.CancelOrder(c.Request().Context(), dto.CancelOrderUsecaseRequest{OrderID: req.OrderID, Reason: req.Reason, Actor: apiActor(c)})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "order cancelled successfully"})
+}
+
+func (h *OrderHandler) ListDeadLetters(c echo.Context) error {
+	var req dto.ListDeadLettersHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.ListDeadLetters(c.Request().Context(), dto.ListDeadLettersUsecaseRequest{Limit: req.Limit, Offset: req.Offset})
+	if err != nil {
+		return err
+	}
+
+	deadLetters := make([]echo.Map, 0, len(res.DeadLetters))
+	for _, deadLetter := range res.DeadLetters {
+		deadLetters = append(deadLetters, echo.Map{
+			"order_id":         deadLetter.OrderID,
+			"priority":         pkg.PriorityName(*deadLetter.Priority),
+			"processing_time":  deadLetter.ProcessingTime,
+			"status":           deadLetter.Status,
+			"attempts":         deadLetter.Attempts,
+			"last_error":       deadLetter.LastError,
+			"created_at":       deadLetter.CreatedAt,
+			"dead_lettered_at": deadLetter.DeadLetteredAt,
+			"attempt_history":  deadLetter.AttemptHistory,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"dead_letters": deadLetters})
+}
+
+func (h *OrderHandler) ReplayDeadLetter(c echo.Context) error {
+	orderID := c.Param("order_id")
+	if orderID == "" {
+		return pkg.ErrInvalidRequest.WithDescription("order_id is required")
+	}
+
+	err := h.usecase.ReplayDeadLetter(c.Request().Context(), dto.ReplayDeadLetterUsecaseRequest{OrderID: orderID, Actor: apiActor(c)})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "dead letter replayed successfully"})
+}
+
+func (h *OrderHandler) ReplayDeadLetters(c echo.Context) error {
+	var req dto.ReplayDeadLettersHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.ReplayDeadLetters(c.Request().Context(), dto.ReplayDeadLettersUsecaseRequest{OrderIDs: req.OrderIDs, All: req.All, Actor: apiActor(c)})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{"replayed": res.OrderIDs, "count": len(res.OrderIDs)})
 }
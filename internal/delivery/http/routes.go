@@ -4,9 +4,33 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterRoutes(e *echo.Echo, orderHandler *OrderHandler) {
+func RegisterRoutes(e *echo.Echo, orderHandler *OrderHandler, webhookHandler *WebhookHandler) {
 	api := e.Group("/api/v1/orders")
 	api.POST("", orderHandler.CreateOrder)
+	api.POST("/batch", orderHandler.CreateOrders)
+	api.GET("", orderHandler.ListOrders)
+	api.GET("/events", orderHandler.StreamOrderEvents)
 	api.GET("/:order_id", orderHandler.GetOrders)
+	api.GET("/:order_id/history", orderHandler.GetOrderHistory)
+	api.GET("/:order_id/events", orderHandler.StreamOrderEvents)
+	api.POST("/:order_id/cancel", orderHandler.CancelOrder)
+
+	deadLetters := e.Group("/api/v1/dead-letters")
+	deadLetters.GET("", orderHandler.ListDeadLetters)
+	deadLetters.POST("/replay", orderHandler.ReplayDeadLetters)
+	deadLetters.POST("/:order_id/replay", orderHandler.ReplayDeadLetter)
+
+	workers := e.Group("/admin/workers")
+	workers.GET("", orderHandler.GetWorkerPool)
+	workers.POST("/pause", orderHandler.PauseWorkers)
+	workers.POST("/resume", orderHandler.ResumeWorkers)
+	workers.POST("/drain", orderHandler.DrainWorkers)
+	workers.POST("/resize", orderHandler.ResizeWorkers)
+
+	webhooks := e.Group("/api/v1/webhooks")
+	webhooks.POST("", webhookHandler.CreateWebhook)
+	webhooks.GET("", webhookHandler.ListWebhooks)
+	webhooks.DELETE("/:webhook_id", webhookHandler.DeleteWebhook)
+	webhooks.GET("/:webhook_id/deliveries", webhookHandler.ListWebhookDeliveries)
 
 }
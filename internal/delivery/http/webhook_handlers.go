@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+type WebhookHandler struct {
+	usecase interfaces.WebhookUseCase
+}
+
+func NewWebhookHandler(usecase interfaces.WebhookUseCase) *WebhookHandler {
+	return &WebhookHandler{usecase: usecase}
+}
+
+func (h *WebhookHandler) CreateWebhook(c echo.Context) error {
+	var req dto.CreateWebhookHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.CreateWebhook(c.Request().Context(), dto.CreateWebhookUsecaseRequest{
+		URL:      req.URL,
+		Secret:   req.Secret,
+		Statuses: req.Statuses,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The secret is only ever returned here
+	return c.JSON(http.StatusCreated, echo.Map{"id": res.ID, "secret": res.Secret})
+}
+
+func (h *WebhookHandler) ListWebhooks(c echo.Context) error {
+	res, err := h.usecase.ListWebhooks(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	webhooks := make([]echo.Map, 0, len(res.Webhooks))
+	for _, webhook := range res.Webhooks {
+		statuses := []string{}
+		if webhook.Statuses != nil && *webhook.Statuses != "" {
+			statuses = strings.Split(*webhook.Statuses, ",")
+		}
+		webhooks = append(webhooks, echo.Map{
+			"id":         webhook.ID,
+			"url":        webhook.URL,
+			"statuses":   statuses,
+			"created_at": webhook.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"webhooks": webhooks})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	webhookID := c.Param("webhook_id")
+	if webhookID == "" {
+		return pkg.ErrInvalidRequest.WithDescription("webhook_id is required")
+	}
+
+	err := h.usecase.DeleteWebhook(c.Request().Context(), webhookID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListWebhookDeliveries(c echo.Context) error {
+	var req dto.ListWebhookDeliveriesHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.ListWebhookDeliveries(c.Request().Context(), dto.ListWebhookDeliveriesUsecaseRequest{
+		WebhookID: req.WebhookID,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"deliveries": res.Deliveries})
+}
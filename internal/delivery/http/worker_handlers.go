@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// A drain waits defaultDrainWait for the orders in flight unless ?wait= is given.
+const (
+	defaultDrainWait = 30 * time.Second
+	maxDrainWait     = 60 * time.Second
+)
+
+func (h *OrderHandler) GetWorkerPool(c echo.Context) error {
+	res, err := h.usecase.GetWorkerPool(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, workerPoolView(res))
+}
+
+func (h *OrderHandler) PauseWorkers(c echo.Context) error {
+	res, err := h.usecase.PauseWorkers(c.Request().Context(), dto.ControlWorkersUsecaseRequest{Actor: apiActor(c)})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, workerPoolView(res))
+}
+
+func (h *OrderHandler) ResumeWorkers(c echo.Context) error {
+	res, err := h.usecase.ResumeWorkers(c.Request().Context(), dto.ControlWorkersUsecaseRequest{Actor: apiActor(c)})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, workerPoolView(res))
+}
+
+// DrainWorkers pauses the workers and answers 200 once the orders in flight have
+// finished, or 202 if they are still running when the wait elapses.
+func (h *OrderHandler) DrainWorkers(c echo.Context) error {
+	wait := defaultDrainWait
+	if param := c.QueryParam("wait"); param != "" {
+		var err error
+		if wait, err = parseWait(param, maxDrainWait); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := h.untilShutdown(c)
+	defer cancel()
+	res, err := h.usecase.DrainWorkers(ctx, dto.DrainWorkersUsecaseRequest{Actor: apiActor(c), Wait: wait})
+	if h.cutShort(c, err) {
+		// Answer with the pool as it is, as if the wait had elapsed
+		res, err = h.usecase.DrainWorkers(c.Request().Context(), dto.DrainWorkersUsecaseRequest{Actor: apiActor(c)})
+	}
+	if requestGone(c, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if res.State == pkg.StatusWorkerPoolDraining {
+		return c.JSON(http.StatusAccepted, workerPoolView(res))
+	}
+	return c.JSON(http.StatusOK, workerPoolView(res))
+}
+
+// ResizeWorkers grows or shrinks the worker pool to the given size.
+func (h *OrderHandler) ResizeWorkers(c echo.Context) error {
+	var req dto.ResizeWorkersHttpHandlerRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return invalidRequest(err)
+	}
+
+	res, err := h.usecase.ResizeWorkers(c.Request().Context(), dto.ResizeWorkersUsecaseRequest{Size: *req.Size, Actor: apiActor(c), Reason: req.Reason})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, workerPoolView(res))
+}
+
+func workerPoolView(res dto.WorkerPoolUsecaseResponse) echo.Map {
+	workers := make([]echo.Map, 0, len(res.Workers))
+	for _, worker := range res.Workers {
+		workers = append(workers, echo.Map{
+			"id":           worker.ID,
+			"owner":        worker.Owner,
+			"reserved_for": worker.ReservedFor,
+			"state":        worker.State,
+			"order_id":     worker.OrderID,
+			"since":        worker.Since,
+		})
+	}
+
+	return echo.Map{
+		"state":         res.State,
+		"size":          res.Size,
+		"workers":       workers,
+		"busy":          res.Busy,
+		"in_flight":     res.InFlight,
+		"changed_at":    res.ChangedAt,
+		"changed_by":    res.ChangedBy,
+		"resized_at":    res.ResizedAt,
+		"resized_by":    res.ResizedBy,
+		"resize_reason": res.ResizeReason,
+		"stats": echo.Map{
+			"claims":             res.Stats.Claims,
+			"contended":          res.Stats.Contended,
+			"processed":          res.Stats.Processed,
+			"processing_seconds": res.Stats.ProcessingTime.Seconds(),
+		},
+	}
+}
@@ -5,10 +5,17 @@ import "time"
 type BaseOrder struct {
 	ID             string     `gorm:"primaryKey;size:100;not null" json:"id"`
 	OrderID        *string    `gorm:"size:100;not null;unique" json:"order_id"`
-	Priority       *string    `gorm:"size:100;not null" json:"priority"`
+	Priority       *int       `gorm:"not null;index" json:"priority"`
 	Status         *string    `gorm:"size:100;not null" json:"status"`
 	ProcessingTime *int       `gorm:"not null;default:0" json:"processing_time"`
-	Lock           *bool      `gorm:"not null;default:0" json:"lock"`
+	LockOwner      *string    `gorm:"size:100;index" json:"lock_owner"`
+	LockAcquiredAt *time.Time `json:"lock_acquired_at"`
+	LockExpiresAt  *time.Time `gorm:"index" json:"lock_expires_at"`
+	CancelReason   *string    `gorm:"size:500" json:"cancel_reason"`
+	Attempts       *int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastError      *string    `gorm:"size:500" json:"last_error"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at"`
 	CreatedAt      *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt      *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -17,8 +24,111 @@ func (BaseOrder) TableName() string {
 	return "orders"
 }
 
+// BaseOrderAttempt records the outcome of one processing attempt of an order.
+type BaseOrderAttempt struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"-"`
+	OrderID    *string    `gorm:"size:100;not null;index" json:"order_id"`
+	Attempt    *int       `gorm:"not null" json:"attempt"`
+	LockOwner  *string    `gorm:"size:100" json:"lock_owner"`
+	Status     *string    `gorm:"size:100;not null" json:"status"`
+	Error      *string    `gorm:"size:500" json:"error"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"finished_at"`
+}
+
+func (BaseOrderAttempt) TableName() string {
+	return "order_attempts"
+}
+
+// BaseOrderEvent records one change in the history of an order: the status it
+// moved from and to, who moved it and why.
+type BaseOrderEvent struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID    *string    `gorm:"size:100;not null;index" json:"order_id"`
+	Actor      *string    `gorm:"size:200" json:"actor"`
+	FromStatus *string    `gorm:"size:100" json:"from_status"`
+	ToStatus   *string    `gorm:"size:100;not null" json:"to_status"`
+	Reason     *string    `gorm:"size:500" json:"reason"`
+	CreatedAt  *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (BaseOrderEvent) TableName() string {
+	return "order_events"
+}
+
+// BaseOutboxMessage is a domain event waiting to be published to the outbox sinks.
+// It is written in the same transaction as the change it describes.
+type BaseOutboxMessage struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Topic         *string    `gorm:"size:100;not null" json:"topic"`
+	AggregateID   *string    `gorm:"size:100;not null" json:"aggregate_id"`
+	Payload       *string    `gorm:"not null" json:"payload"`
+	Attempts      *int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastError     *string    `gorm:"size:500" json:"last_error"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at"`
+	CreatedAt     *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (BaseOutboxMessage) TableName() string {
+	return "outbox"
+}
+
+// BaseWebhookSubscription is an endpoint that receives order events. Statuses is a
+// comma separated list of the statuses whose events it receives; empty receives
+// every event.
+type BaseWebhookSubscription struct {
+	ID        string     `gorm:"primaryKey;size:100;not null" json:"id"`
+	URL       *string    `gorm:"size:2000;not null" json:"url"`
+	Secret    *string    `gorm:"size:200;not null" json:"-"`
+	Statuses  *string    `gorm:"size:500" json:"statuses"`
+	CreatedAt *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (BaseWebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// BaseWebhookDelivery is one order event to be delivered to one subscription. It
+// is added by the webhook outbox sink, and keeps the outcome of the latest
+// delivery attempt.
+type BaseWebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID *string    `gorm:"size:100;not null;uniqueIndex:idx_webhook_deliveries_subscription_event" json:"subscription_id"`
+	EventID        *uint      `gorm:"not null;uniqueIndex:idx_webhook_deliveries_subscription_event" json:"event_id"`
+	OrderID        *string    `gorm:"size:100;not null" json:"order_id"`
+	Status         *string    `gorm:"size:100;not null;index" json:"status"`
+	Attempts       *int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	ResponseCode   *int       `json:"response_code"`
+	LastError      *string    `gorm:"size:500" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (BaseWebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// BaseIdempotencyKey remembers the request an idempotency key was first used for
+// and the response it got, until ExpiresAt.
+type BaseIdempotencyKey struct {
+	Key          string     `gorm:"primaryKey;size:255;not null" json:"key"`
+	RequestHash  *string    `gorm:"size:64;not null" json:"request_hash"`
+	OrderID      *string    `gorm:"size:100;not null" json:"order_id"`
+	ResponseCode *int       `gorm:"not null" json:"response_code"`
+	ResponseBody *string    `gorm:"not null" json:"response_body"`
+	ExpiresAt    *time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    *time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (BaseIdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
 type BaseCreateOrderRequest struct {
 	OrderID        string `json:"order_id" validate:"required,min=2,max=50"`
-	Priority       string `json:"priority" validate:"required,oneof=High Normal"`
+	Priority       string `json:"priority" validate:"required,oneof=Critical High Normal Low Bulk"`
 	ProcessingTime int    `json:"processing_time" validate:"required,min=1,max=100"`
 }
@@ -1,5 +1,57 @@
 package dto
 
+import "time"
+
 type CreateOrderHttpHandlerRequest struct {
 	BaseCreateOrderRequest
 }
+
+type CancelOrderHttpHandlerRequest struct {
+	OrderID string `param:"order_id" validate:"required"`
+	Reason  string `json:"reason" validate:"max=500"`
+}
+
+type ListDeadLettersHttpHandlerRequest struct {
+	Limit  int `query:"limit" validate:"min=0,max=500"`
+	Offset int `query:"offset" validate:"min=0"`
+}
+
+// ReplayDeadLettersHttpHandlerRequest selects the dead letters to replay by ID, or
+// every one of them with All.
+type ReplayDeadLettersHttpHandlerRequest struct {
+	OrderIDs []string `json:"order_ids" validate:"max=1000,dive,required"`
+	All      bool     `json:"all"`
+}
+
+type ResizeWorkersHttpHandlerRequest struct {
+	Size   *int   `json:"size" validate:"required,min=0,max=256"`
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+// ListOrdersHttpHandlerRequest holds the query of the order listing. Status and
+// priority filters may be repeated or comma separated.
+type ListOrdersHttpHandlerRequest struct {
+	Statuses      []string   `query:"status" validate:"dive,oneof=Pending Scheduled Running Processed Failed Cancelled DeadLettered"`
+	Priorities    []string   `query:"priority" validate:"dive,oneof=Critical High Normal Low Bulk"`
+	CreatedAfter  *time.Time `query:"created_after"`
+	CreatedBefore *time.Time `query:"created_before"`
+	UpdatedAfter  *time.Time `query:"updated_after"`
+	UpdatedBefore *time.Time `query:"updated_before"`
+	Locked        *bool      `query:"locked"`
+	SortBy        string     `query:"sort" validate:"omitempty,oneof=created_at updated_at priority"`
+	Order         string     `query:"order" validate:"omitempty,oneof=asc desc"`
+	Cursor        string     `query:"cursor"`
+	Limit         int        `query:"limit" validate:"min=0,max=500"`
+}
+
+type CreateWebhookHttpHandlerRequest struct {
+	URL      string   `json:"url" validate:"required,url,max=2000"`
+	Secret   string   `json:"secret" validate:"omitempty,min=16,max=200"`
+	Statuses []string `json:"statuses" validate:"dive,oneof=Pending Scheduled Running Processed Failed Cancelled DeadLettered"`
+}
+
+type ListWebhookDeliveriesHttpHandlerRequest struct {
+	WebhookID string `param:"webhook_id" validate:"required"`
+	Limit     int    `query:"limit" validate:"min=0,max=500"`
+	Offset    int    `query:"offset" validate:"min=0"`
+}
@@ -1,22 +1,228 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// StatusTransition restricts a repository update to orders in one of the From
+// statuses and moves them to the To status. Actor and Reason are recorded in the
+// history of every order moved.
+type StatusTransition struct {
+	From   []string
+	To     string
+	Actor  string
+	Reason string
+}
+
+// CreatOrderRepositoryRequest carries the order to create. IdempotencyKey, if
+// set, is stored in the same transaction, after removing expired keys.
 type CreatOrderRepositoryRequest struct {
 	BaseOrder
+	Actor          string              `gorm:"-" json:"-"`
+	IdempotencyKey *BaseIdempotencyKey `gorm:"-" json:"-"`
+}
+
+// CreateOrdersRepositoryRequest carries the orders of a batch, created by Actor.
+type CreateOrdersRepositoryRequest struct {
+	Orders []BaseOrder
+	Actor  string
+}
+
+// CreateOrdersRepositoryResponse lists the order IDs of the batch that were
+// already in use, and so were not created.
+type CreateOrdersRepositoryResponse struct {
+	Duplicates []string
+}
+
+// GetIdempotencyKeyRepositoryRequest looks up a key that has not expired at Now.
+type GetIdempotencyKeyRepositoryRequest struct {
+	Key string
+	Now time.Time
+}
+
+type GetIdempotencyKeyRepositoryResponse struct {
+	BaseIdempotencyKey
 }
 
 type UpdateOrderByIDRepositoryRequest struct {
 	BaseOrder
 }
 
-type LockOrderOptimisticRepositoryRequest struct {
+// ClaimNextOrderRepositoryRequest carries the lease to take and the aging policy
+// used to rank the waiting orders at Now. Orders whose priority level is below
+// MinPriority are not claimed; aging does not raise them above it.
+type ClaimNextOrderRepositoryRequest struct {
 	BaseOrder
+	Transition  StatusTransition
+	Aging       pkg.PriorityAging
+	Now         time.Time
+	MinPriority int
 }
 
-type GetOrderByIDRepositoryResponse struct {
+type ClaimNextOrderRepositoryResponse struct {
 	BaseOrder
 }
 
-type GetNextHighPriorityReadyOrderRepositoryResponse struct {
+type RenewOrderLockRepositoryRequest struct {
+	BaseOrder
+}
+
+// CompleteOrderRepositoryRequest carries the result of an attempt; Attempt is
+// recorded in the attempt history in the same transaction.
+type CompleteOrderRepositoryRequest struct {
+	BaseOrder
+	Transition StatusTransition
+	Attempt    BaseOrderAttempt
+}
+
+type CancelOrderRepositoryRequest struct {
+	BaseOrder
+	Transition StatusTransition
+}
+
+// ReleaseOrphanedLocksRepositoryRequest selects the leases to return to the queue:
+// every lease that expired before Now, plus every lease held by InstanceID when set.
+type ReleaseOrphanedLocksRepositoryRequest struct {
+	InstanceID string
+	Now        time.Time
+	Transition StatusTransition
+}
+
+type ListDeadLettersRepositoryRequest struct {
+	Limit  int
+	Offset int
+}
+
+type DeadLetterRepositoryResponse struct {
+	BaseOrder
+	AttemptHistory []BaseOrderAttempt
+}
+
+type ListDeadLettersRepositoryResponse struct {
+	DeadLetters []DeadLetterRepositoryResponse
+}
+
+// ReplayDeadLettersRepositoryRequest selects the dead letters to replay by ID, or
+// every one of them with All.
+type ReplayDeadLettersRepositoryRequest struct {
+	OrderIDs   []string
+	All        bool
+	Transition StatusTransition
+}
+
+type ReplayDeadLettersRepositoryResponse struct {
+	OrderIDs []string
+}
+
+// OrderCursor is the position after which ListOrders continues: the sort key value
+// and ID of the last order of the previous page.
+type OrderCursor struct {
+	SortBy     string  `json:"s"`
+	Descending bool    `json:"d"`
+	Value      float64 `json:"v"`
+	ID         string  `json:"i"`
+}
+
+// ListOrdersRepositoryRequest filters, sorts and pages orders. Empty filters match
+// every order; SortBy is one of created_at, updated_at and priority.
+type ListOrdersRepositoryRequest struct {
+	Statuses      []string
+	Priorities    []int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Locked        *bool
+	SortBy        string
+	Descending    bool
+	After         *OrderCursor
+	Limit         int
+}
+
+// ListOrdersRepositoryResponse holds one page of orders; Next is nil on the last page.
+type ListOrdersRepositoryResponse struct {
+	Orders []BaseOrder
+	Next   *OrderCursor
+}
+
+// ListOrderEventsRepositoryRequest selects the events recorded after AfterID, of
+// a single order when OrderID is set. A Limit of 0 selects all of them.
+type ListOrderEventsRepositoryRequest struct {
+	OrderID string
+	AfterID uint
+	Limit   int
+}
+
+type ListOrderEventsRepositoryResponse struct {
+	Events []BaseOrderEvent
+}
+
+// ClaimOutboxMessagesRepositoryRequest selects up to Limit unpublished messages due
+// at Now, oldest first, and postpones them to Until, so that no other relay
+// publishes them meanwhile.
+type ClaimOutboxMessagesRepositoryRequest struct {
+	Now   time.Time
+	Until time.Time
+	Limit int
+}
+
+type ClaimOutboxMessagesRepositoryResponse struct {
+	Messages []BaseOutboxMessage
+}
+
+type CompleteOutboxMessageRepositoryRequest struct {
+	BaseOutboxMessage
+}
+
+type DeletePublishedOutboxMessagesRepositoryRequest struct {
+	PublishedBefore time.Time
+}
+
+type CreateWebhookSubscriptionRepositoryRequest struct {
+	BaseWebhookSubscription
+}
+
+type ListWebhookSubscriptionsRepositoryResponse struct {
+	Subscriptions []BaseWebhookSubscription
+}
+
+type ListWebhookDeliveriesRepositoryRequest struct {
+	SubscriptionID string
+	Limit          int
+	Offset         int
+}
+
+type ListWebhookDeliveriesRepositoryResponse struct {
+	Deliveries []BaseWebhookDelivery
+}
+
+// ClaimWebhookDeliveriesRepositoryRequest selects up to Limit deliveries due at Now
+// and postpones them to Until, so that no other dispatcher sends them meanwhile.
+type ClaimWebhookDeliveriesRepositoryRequest struct {
+	Now   time.Time
+	Until time.Time
+	Limit int
+}
+
+// WebhookDeliveryRepositoryResponse is a delivery with the subscription it goes
+// to and the event it carries.
+type WebhookDeliveryRepositoryResponse struct {
+	BaseWebhookDelivery
+	Subscription BaseWebhookSubscription
+	Event        BaseOrderEvent
+}
+
+type ClaimWebhookDeliveriesRepositoryResponse struct {
+	Deliveries []WebhookDeliveryRepositoryResponse
+}
+
+type CompleteWebhookDeliveryRepositoryRequest struct {
+	BaseWebhookDelivery
+}
+
+type GetOrderByIDRepositoryResponse struct {
 	BaseOrder
 }
 
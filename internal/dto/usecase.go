@@ -1,9 +1,211 @@
 package dto
 
+import "time"
+
+// CreateOrderUsecaseRequest creates an order. With an IdempotencyKey, Response is
+// stored with the key and replayed for retries of the same request.
 type CreateOrderUsecaseRequest struct {
 	BaseCreateOrderRequest
+	Actor          string
+	IdempotencyKey string
+	Response       IdempotentResponse
+}
+
+// CreateOrderUsecaseResponse is the response to give; Replayed tells whether it is
+// the stored response of an earlier request with the same idempotency key.
+type CreateOrderUsecaseResponse struct {
+	Response IdempotentResponse
+	Replayed bool
+}
+
+// CreateOrdersUsecaseRequest creates the orders of a batch.
+type CreateOrdersUsecaseRequest struct {
+	Orders []BaseCreateOrderRequest
+	Actor  string
+}
+
+// CreateOrdersUsecaseResponse has a result per order, in the order of the request.
+type CreateOrdersUsecaseResponse struct {
+	Results []CreateOrderResult
+}
+
+// CreateOrderResult tells whether an order of a batch was created, and why not.
+type CreateOrderResult struct {
+	OrderID string
+	Status  string
+	Error   string
+}
+
+// IdempotentResponse is a response stored with an idempotency key.
+type IdempotentResponse struct {
+	Code int
+	Body []byte
+}
+
+type CancelOrderUsecaseRequest struct {
+	OrderID string
+	Reason  string
+	Actor   string
 }
 
 type GetOrderUsecaseResponse struct {
 	BaseOrder
+	PriorityName      string
+	EffectivePriority int
+}
+
+// WaitOrderUsecaseRequest waits up to Wait for the processing of an order to end.
+type WaitOrderUsecaseRequest struct {
+	OrderID string
+	Wait    time.Duration
+}
+
+// WaitOrderUsecaseResponse is the order as last seen; Ended tells whether its
+// processing ended before the wait elapsed.
+type WaitOrderUsecaseResponse struct {
+	GetOrderUsecaseResponse
+	Ended bool
+}
+
+type ListDeadLettersUsecaseRequest struct {
+	Limit  int
+	Offset int
+}
+
+type ListDeadLettersUsecaseResponse struct {
+	DeadLetters []DeadLetterRepositoryResponse
+}
+
+type ReplayDeadLetterUsecaseRequest struct {
+	OrderID string
+	Actor   string
+}
+
+type ReplayDeadLettersUsecaseRequest struct {
+	OrderIDs []string
+	All      bool
+	Actor    string
+}
+
+type ReplayDeadLettersUsecaseResponse struct {
+	OrderIDs []string
+}
+
+type ListOrdersUsecaseRequest struct {
+	Statuses      []string
+	Priorities    []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Locked        *bool
+	SortBy        string
+	Order         string
+	Cursor        string
+	Limit         int
+}
+
+type ListOrdersUsecaseResponse struct {
+	Orders     []GetOrderUsecaseResponse
+	NextCursor string
+}
+
+// StreamOrderEventsUsecaseRequest selects the events to stream: those of a single
+// order when OrderID is set, recorded after AfterID. Without AfterID a stream of
+// all orders starts with the next event and a stream of one order replays its
+// whole history first.
+type StreamOrderEventsUsecaseRequest struct {
+	OrderID string
+	AfterID *uint
+}
+
+type CreateWebhookUsecaseRequest struct {
+	URL      string
+	Secret   string
+	Statuses []string
+}
+
+// CreateWebhookUsecaseResponse returns the secret the payloads are signed with,
+// generated unless one was given.
+type CreateWebhookUsecaseResponse struct {
+	ID     string
+	Secret string
+}
+
+type ListWebhooksUsecaseResponse struct {
+	Webhooks []BaseWebhookSubscription
+}
+
+type ListWebhookDeliveriesUsecaseRequest struct {
+	WebhookID string
+	Limit     int
+	Offset    int
+}
+
+type ListWebhookDeliveriesUsecaseResponse struct {
+	Deliveries []BaseWebhookDelivery
+}
+
+type GetOrderHistoryUsecaseResponse struct {
+	Events []BaseOrderEvent
+}
+
+type ControlWorkersUsecaseRequest struct {
+	Actor string
+}
+
+// DrainWorkersUsecaseRequest pauses the workers and waits up to Wait for the
+// orders they are processing to finish.
+type DrainWorkersUsecaseRequest struct {
+	Actor string
+	Wait  time.Duration
+}
+
+// ResizeWorkersUsecaseRequest sets the number of workers of the pool.
+type ResizeWorkersUsecaseRequest struct {
+	Size   int
+	Actor  string
+	Reason string
+}
+
+// WorkerPoolUsecaseResponse describes the worker pool of this process. Busy
+// workers are claiming or processing an order, listed in InFlight. Workers holds
+// Size shared workers and the reserved ones, plus the retired ones still
+// finishing their order. ChangedAt and
+// ChangedBy tell the last pause or resume, ResizedAt, ResizedBy and ResizeReason
+// the last resize.
+type WorkerPoolUsecaseResponse struct {
+	State        string
+	Size         int
+	Workers      []WorkerUsecaseResponse
+	Busy         int
+	InFlight     []string
+	ChangedAt    *time.Time
+	ChangedBy    string
+	ResizedAt    *time.Time
+	ResizedBy    string
+	ResizeReason string
+	Stats        WorkerStats
+}
+
+// WorkerStats counts, since the process started, the claims of the workers, the
+// claims that failed on database contention, and the orders processed with the
+// time spent processing them.
+type WorkerStats struct {
+	Claims         int64
+	Contended      int64
+	Processed      int64
+	ProcessingTime time.Duration
+}
+
+// WorkerUsecaseResponse describes a worker: idle, busy processing OrderID, or
+// retiring once that order is done, since Since. A worker ReservedFor a priority
+// only claims orders of that priority or above.
+type WorkerUsecaseResponse struct {
+	ID          int
+	Owner       string
+	ReservedFor string
+	State       string
+	OrderID     *string
+	Since       time.Time
 }
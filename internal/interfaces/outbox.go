@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+)
+
+// OutboxSink publishes outbox messages. A message may be published again after a
+// failure of this or another sink, so sinks must tolerate duplicates.
+type OutboxSink interface {
+	Name() string
+	Publish(ctx context.Context, message dto.BaseOutboxMessage) error
+}
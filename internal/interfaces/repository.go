@@ -10,14 +10,61 @@ import (
 // OrderRepository is an interface that defines the methods for interacting with the order repository.
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, params dto.CreatOrderRepositoryRequest) (err error)
+	CreateOrders(ctx context.Context, params dto.CreateOrdersRepositoryRequest) (res dto.CreateOrdersRepositoryResponse, err error)
+	GetIdempotencyKey(ctx context.Context, params dto.GetIdempotencyKeyRepositoryRequest) (res dto.GetIdempotencyKeyRepositoryResponse, err error)
 
 	GetOrderByID(ctx context.Context, orderID string) (res dto.GetOrderByIDRepositoryResponse, err error)
 
-	GetNextHighPriorityReadyOrder(ctx context.Context) (res dto.GetNextHighPriorityReadyOrderRepositoryResponse, err error)
+	ListOrders(ctx context.Context, params dto.ListOrdersRepositoryRequest) (res dto.ListOrdersRepositoryResponse, err error)
 
 	UpdateOrderByID(ctx context.Context, params dto.UpdateOrderByIDRepositoryRequest) (err error)
 
 	ListAggregateOrderReport(ctx context.Context) (counts map[string]int, err error)
 
-	LockOrderOptimistic(ctx context.Context, params dto.LockOrderOptimisticRepositoryRequest) (err error)
+	ClaimNextOrder(ctx context.Context, params dto.ClaimNextOrderRepositoryRequest) (res dto.ClaimNextOrderRepositoryResponse, err error)
+
+	RenewOrderLock(ctx context.Context, params dto.RenewOrderLockRepositoryRequest) (err error)
+
+	CompleteOrder(ctx context.Context, params dto.CompleteOrderRepositoryRequest) (err error)
+
+	CancelOrder(ctx context.Context, params dto.CancelOrderRepositoryRequest) (err error)
+
+	ListDeadLetters(ctx context.Context, params dto.ListDeadLettersRepositoryRequest) (res dto.ListDeadLettersRepositoryResponse, err error)
+
+	ReplayDeadLetters(ctx context.Context, params dto.ReplayDeadLettersRepositoryRequest) (res dto.ReplayDeadLettersRepositoryResponse, err error)
+
+	ReleaseOrphanedLocks(ctx context.Context, params dto.ReleaseOrphanedLocksRepositoryRequest) (released int64, err error)
+
+	CountPendingOrders(ctx context.Context) (count int64, err error)
+
+	ListOrderEvents(ctx context.Context, params dto.ListOrderEventsRepositoryRequest) (res dto.ListOrderEventsRepositoryResponse, err error)
+
+	LatestOrderEventID(ctx context.Context) (id uint, err error)
+}
+
+// WebhookRepository stores webhook subscriptions and their deliveries.
+type WebhookRepository interface {
+	CreateWebhookSubscription(ctx context.Context, params dto.CreateWebhookSubscriptionRepositoryRequest) (err error)
+
+	ListWebhookSubscriptions(ctx context.Context) (res dto.ListWebhookSubscriptionsRepositoryResponse, err error)
+
+	DeleteWebhookSubscription(ctx context.Context, subscriptionID string) (err error)
+
+	ListWebhookDeliveries(ctx context.Context, params dto.ListWebhookDeliveriesRepositoryRequest) (res dto.ListWebhookDeliveriesRepositoryResponse, err error)
+
+	EnqueueWebhookDeliveries(ctx context.Context, event dto.BaseOrderEvent) (err error)
+
+	ClaimWebhookDeliveries(ctx context.Context, params dto.ClaimWebhookDeliveriesRepositoryRequest) (res dto.ClaimWebhookDeliveriesRepositoryResponse, err error)
+
+	CompleteWebhookDelivery(ctx context.Context, params dto.CompleteWebhookDeliveryRepositoryRequest) (err error)
+}
+
+// OutboxRepository reads the outbox messages that the OrderRepository writes
+// together with every order event.
+type OutboxRepository interface {
+	ClaimOutboxMessages(ctx context.Context, params dto.ClaimOutboxMessagesRepositoryRequest) (res dto.ClaimOutboxMessagesRepositoryResponse, err error)
+
+	CompleteOutboxMessage(ctx context.Context, params dto.CompleteOutboxMessageRepositoryRequest) (err error)
+
+	DeletePublishedOutboxMessages(ctx context.Context, params dto.DeletePublishedOutboxMessagesRepositoryRequest) (deleted int64, err error)
 }
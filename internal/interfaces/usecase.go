@@ -8,8 +8,47 @@ import (
 
 // OrderUseCase defines the methods that the use case layer will implement.
 type OrderUseCase interface {
-	CreateOrder(ctx context.Context, params dto.CreateOrderUsecaseRequest) error
+	CreateOrder(ctx context.Context, params dto.CreateOrderUsecaseRequest) (res dto.CreateOrderUsecaseResponse, err error)
+	CreateOrders(ctx context.Context, params dto.CreateOrdersUsecaseRequest) (res dto.CreateOrdersUsecaseResponse, err error)
 	ProcessOrder(ctx context.Context) error
 	ListAggregateOrderReport(ctx context.Context) error
 	GetOrder(ctx context.Context, orderID string) (res dto.GetOrderUsecaseResponse, err error)
+	WaitOrder(ctx context.Context, params dto.WaitOrderUsecaseRequest) (res dto.WaitOrderUsecaseResponse, err error)
+	GetOrderHistory(ctx context.Context, orderID string) (res dto.GetOrderHistoryUsecaseResponse, err error)
+	StreamOrderEvents(ctx context.Context, params dto.StreamOrderEventsUsecaseRequest) (events <-chan dto.BaseOrderEvent, err error)
+	ListOrders(ctx context.Context, params dto.ListOrdersUsecaseRequest) (res dto.ListOrdersUsecaseResponse, err error)
+	CancelOrder(ctx context.Context, params dto.CancelOrderUsecaseRequest) error
+	ListDeadLetters(ctx context.Context, params dto.ListDeadLettersUsecaseRequest) (res dto.ListDeadLettersUsecaseResponse, err error)
+	ReplayDeadLetter(ctx context.Context, params dto.ReplayDeadLetterUsecaseRequest) error
+	ReplayDeadLetters(ctx context.Context, params dto.ReplayDeadLettersUsecaseRequest) (res dto.ReplayDeadLettersUsecaseResponse, err error)
+	GetWorkerPool(ctx context.Context) (res dto.WorkerPoolUsecaseResponse, err error)
+	PauseWorkers(ctx context.Context, params dto.ControlWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error)
+	ResumeWorkers(ctx context.Context, params dto.ControlWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error)
+	DrainWorkers(ctx context.Context, params dto.DrainWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error)
+	ResizeWorkers(ctx context.Context, params dto.ResizeWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error)
+}
+
+// WebhookUseCase manages webhook subscriptions and delivers order events to them.
+type WebhookUseCase interface {
+	CreateWebhook(ctx context.Context, params dto.CreateWebhookUsecaseRequest) (res dto.CreateWebhookUsecaseResponse, err error)
+	ListWebhooks(ctx context.Context) (res dto.ListWebhooksUsecaseResponse, err error)
+	DeleteWebhook(ctx context.Context, webhookID string) error
+	ListWebhookDeliveries(ctx context.Context, params dto.ListWebhookDeliveriesUsecaseRequest) (res dto.ListWebhookDeliveriesUsecaseResponse, err error)
+	DispatchWebhooks(ctx context.Context) error
+}
+
+// WorkerPool is the worker pool an Autoscaler resizes.
+type WorkerPool interface {
+	GetWorkerPool(ctx context.Context) (res dto.WorkerPoolUsecaseResponse, err error)
+	ResizeWorkers(ctx context.Context, params dto.ResizeWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error)
+}
+
+// Autoscaler resizes a worker pool to the load.
+type Autoscaler interface {
+	AutoscaleWorkers(ctx context.Context) error
+}
+
+// OutboxRelay publishes the outbox to the outbox sinks.
+type OutboxRelay interface {
+	RelayOutbox(ctx context.Context) error
 }
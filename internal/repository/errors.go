@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/seyedmo30/order_management/pkg"
+	"gorm.io/gorm"
+)
+
+// translateError maps the GORM and SQLite errors the repository hands out to the
+// error kinds of pkg, leaving other errors as they are.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return pkg.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return pkg.ErrDuplicateEntry
+	case errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked):
+		return pkg.ErrUnavailable.WithDescription(err.Error())
+	}
+	return err
+}
+
+// registerErrorTranslation translates the error of every statement run through
+// db, so that callers only ever see the error kinds of pkg.
+func registerErrorTranslation(db *gorm.DB) error {
+	translate := func(tx *gorm.DB) {
+		if tx.Error != nil {
+			tx.Error = translateError(tx.Error)
+		}
+	}
+
+	callbacks := db.Callback()
+	for _, register := range []func(string, func(*gorm.DB)) error{
+		callbacks.Create().Register,
+		callbacks.Query().Register,
+		callbacks.Update().Register,
+		callbacks.Delete().Register,
+		callbacks.Row().Register,
+		callbacks.Raw().Register,
+	} {
+		if err := register("order_management:translate_error", translate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
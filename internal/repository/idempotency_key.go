@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"gorm.io/gorm"
+)
+
+// GetIdempotencyKey returns an idempotency key that has not expired yet.
+func (r *orderManagementRepository) GetIdempotencyKey(ctx context.Context, params dto.GetIdempotencyKeyRepositoryRequest) (res dto.GetIdempotencyKeyRepositoryResponse, err error) {
+	err = db.WithContext(ctx).
+		Where("key = ?", params.Key).
+		Where("julianday(expires_at) > julianday(?)", params.Now).
+		First(&res.BaseIdempotencyKey).Error
+	return
+}
+
+// storeIdempotencyKey removes the expired idempotency keys, which frees them for
+// reuse, and stores the given one.
+func storeIdempotencyKey(tx *gorm.DB, key dto.BaseIdempotencyKey) error {
+	err := tx.
+		Where("julianday(expires_at) <= julianday(?)", key.CreatedAt).
+		Delete(&dto.BaseIdempotencyKey{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&key).Error
+}
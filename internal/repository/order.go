@@ -2,17 +2,95 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/seyedmo30/order_management/internal/dto"
 	"github.com/seyedmo30/order_management/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// createBatchSize bounds the rows inserted per statement, keeping bulk inserts
+// within the SQLite limit on bound parameters.
+const createBatchSize = 200
+
+// CreateOrder stores a new order and records its creation as the first event of
+// its history. An order ID or idempotency key already in use is a 409 error.
 func (r *orderManagementRepository) CreateOrder(ctx context.Context, params dto.CreatOrderRepositoryRequest) (err error) {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if params.IdempotencyKey != nil {
+			if err := storeIdempotencyKey(tx, *params.IdempotencyKey); err != nil {
+				return err
+			}
+		}
 
-	if err = db.WithContext(ctx).Table("orders").Create(&params).Error; err != nil {
-		return
-	}
-	return nil
+		if err := tx.Table("orders").Create(&params).Error; err != nil {
+			return err
+		}
+
+		event := orderEvent(params.OrderID, nil, dto.StatusTransition{
+			To:     *params.Status,
+			Actor:  params.Actor,
+			Reason: "order created",
+		}, time.Now())
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return enqueueOutboxMessages(tx, []dto.BaseOrderEvent{event})
+	})
+}
+
+// CreateOrders stores the orders of a batch in one transaction and records their
+// creation. Orders whose order ID is already in use are skipped and returned as
+// duplicates.
+func (r *orderManagementRepository) CreateOrders(ctx context.Context, params dto.CreateOrdersRepositoryRequest) (res dto.CreateOrdersRepositoryResponse, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orderIDs := make([]string, 0, len(params.Orders))
+		for _, order := range params.Orders {
+			orderIDs = append(orderIDs, *order.OrderID)
+		}
+
+		err := tx.Model(&dto.BaseOrder{}).
+			Where("order_id IN ?", orderIDs).
+			Pluck("order_id", &res.Duplicates).Error
+		if err != nil {
+			return err
+		}
+
+		duplicates := make(map[string]bool, len(res.Duplicates))
+		for _, orderID := range res.Duplicates {
+			duplicates[orderID] = true
+		}
+
+		now := time.Now()
+		orders := make([]dto.BaseOrder, 0, len(params.Orders))
+		events := make([]dto.BaseOrderEvent, 0, len(params.Orders))
+		for _, order := range params.Orders {
+			if duplicates[*order.OrderID] {
+				continue
+			}
+			orders = append(orders, order)
+			events = append(events, orderEvent(order.OrderID, nil, dto.StatusTransition{
+				To:     *order.Status,
+				Actor:  params.Actor,
+				Reason: "order created",
+			}, now))
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		if err := tx.CreateInBatches(&orders, createBatchSize).Error; err != nil {
+			return err
+		}
+		if err := tx.CreateInBatches(&events, createBatchSize).Error; err != nil {
+			return err
+		}
+		return enqueueOutboxMessages(tx, events)
+	})
+	return
 }
 
 func (r *orderManagementRepository) GetOrderByID(ctx context.Context, orderID string) (res dto.GetOrderByIDRepositoryResponse, err error) {
@@ -23,10 +101,98 @@ func (r *orderManagementRepository) GetOrderByID(ctx context.Context, orderID st
 	return
 }
 
+// listOrdersSortKeys maps the sort options of ListOrders to their sort key. Times
+// are compared as Julian days so that the stored and the bound formats agree.
+var listOrdersSortKeys = map[string]string{
+	"created_at": "julianday(created_at)",
+	"updated_at": "julianday(updated_at)",
+	"priority":   "priority",
+}
+
+// ListOrders returns one page of the orders matching the filters, using keyset
+// pagination on the sort key and ID so that pages stay stable while orders change.
+func (r *orderManagementRepository) ListOrders(ctx context.Context, params dto.ListOrdersRepositoryRequest) (res dto.ListOrdersRepositoryResponse, err error) {
+	sortKey, ok := listOrdersSortKeys[params.SortBy]
+	if !ok {
+		sortKey = listOrdersSortKeys["created_at"]
+	}
+
+	query := db.WithContext(ctx).Table("orders")
+
+	if len(params.Statuses) > 0 {
+		query = query.Where("status IN ?", params.Statuses)
+	}
+	if len(params.Priorities) > 0 {
+		query = query.Where("priority IN ?", params.Priorities)
+	}
+	if params.CreatedAfter != nil {
+		query = query.Where("julianday(created_at) >= julianday(?)", *params.CreatedAfter)
+	}
+	if params.CreatedBefore != nil {
+		query = query.Where("julianday(created_at) < julianday(?)", *params.CreatedBefore)
+	}
+	if params.UpdatedAfter != nil {
+		query = query.Where("julianday(updated_at) >= julianday(?)", *params.UpdatedAfter)
+	}
+	if params.UpdatedBefore != nil {
+		query = query.Where("julianday(updated_at) < julianday(?)", *params.UpdatedBefore)
+	}
+	if params.Locked != nil {
+		if *params.Locked {
+			query = query.Where("lock_owner IS NOT NULL")
+		} else {
+			query = query.Where("lock_owner IS NULL")
+		}
+	}
+
+	direction, comparison := "ASC", ">"
+	if params.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if params.After != nil {
+		query = query.Where(
+			fmt.Sprintf("%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?)", sortKey, comparison),
+			params.After.Value, params.After.Value, params.After.ID,
+		)
+	}
+
+	var rows []struct {
+		dto.BaseOrder
+		SortValue float64
+	}
+	err = query.
+		Select(fmt.Sprintf("*, %s AS sort_value", sortKey)).
+		Order(fmt.Sprintf("%[1]s %[2]s, id %[2]s", sortKey, direction)).
+		Limit(params.Limit + 1).
+		Find(&rows).Error
+	if err != nil {
+		return
+	}
+
+	// The extra row only tells whether there is a next page
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		last := rows[len(rows)-1]
+		res.Next = &dto.OrderCursor{
+			SortBy:     params.SortBy,
+			Descending: params.Descending,
+			Value:      last.SortValue,
+			ID:         last.ID,
+		}
+	}
+
+	res.Orders = make([]dto.BaseOrder, 0, len(rows))
+	for _, row := range rows {
+		res.Orders = append(res.Orders, row.BaseOrder)
+	}
+	return
+}
+
 func (r *orderManagementRepository) UpdateOrderByID(ctx context.Context, params dto.UpdateOrderByIDRepositoryRequest) (err error) {
 	result := db.WithContext(ctx).
 		Table("orders").
 		Where("order_id = ?", params.OrderID).
+		Omit("status").
 		Updates(&params)
 
 	if err := result.Error; err != nil {
@@ -35,10 +201,7 @@ func (r *orderManagementRepository) UpdateOrderByID(ctx context.Context, params
 
 	if result.RowsAffected == 0 {
 
-		err = &pkg.ErrorCustom{
-			Code:    404,
-			Message: pkg.NotFoundRepositoryMessage,
-		}
+		err = pkg.ErrNotFound
 
 		return err
 	}
@@ -46,12 +209,47 @@ func (r *orderManagementRepository) UpdateOrderByID(ctx context.Context, params
 	return
 }
 
-func (r *orderManagementRepository) LockOrderOptimistic(ctx context.Context, params dto.LockOrderOptimisticRepositoryRequest) (err error) {
+// ClaimNextOrder selects the ready order with the highest effective priority and
+// takes a lease on it for params.LockOwner. The order is selected and updated in
+// one transaction, and the update only applies while the order is still in its
+// selected status, so concurrent workers can never claim the same order.
+func (r *orderManagementRepository) ClaimNextOrder(ctx context.Context, params dto.ClaimNextOrderRepositoryRequest) (res dto.ClaimNextOrderRepositoryResponse, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		claimed, err := transitionOrders(tx, params.Transition, map[string]interface{}{
+			"lock_owner":       params.LockOwner,
+			"lock_acquired_at": params.LockAcquiredAt,
+			"lock_expires_at":  params.LockExpiresAt,
+			"attempts":         gorm.Expr("attempts + 1"),
+		}, func(query *gorm.DB) *gorm.DB {
+			if params.MinPriority > 0 {
+				query = query.Where("priority >= ?", params.MinPriority)
+			}
+			return query.
+				Where("lock_owner IS NULL").
+				Where("next_attempt_at IS NULL OR julianday(next_attempt_at) <= julianday(?)", params.Now).
+				Clauses(effectivePriorityOrder(params.Aging, params.Now)).
+				Limit(1)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(claimed) == 0 {
+			return pkg.ErrNotFound
+		}
+
+		return tx.Table("orders").Where("id = ?", claimed[0].ID).First(&res).Error
+	})
+	return
+}
+
+// RenewOrderLock extends the lease held by params.LockOwner to params.LockExpiresAt.
+func (r *orderManagementRepository) RenewOrderLock(ctx context.Context, params dto.RenewOrderLockRepositoryRequest) (err error) {
 	result := db.WithContext(ctx).
 		Table("orders").
-		Where("lock = ?", false).
 		Where("order_id = ?", params.OrderID).
-		Update("lock", true)
+		Where("lock_owner = ?", params.LockOwner).
+		Update("lock_expires_at", params.LockExpiresAt)
 
 	if err := result.Error; err != nil {
 		return err
@@ -59,10 +257,7 @@ func (r *orderManagementRepository) LockOrderOptimistic(ctx context.Context, par
 
 	if result.RowsAffected == 0 {
 
-		err = &pkg.ErrorCustom{
-			Code:    404,
-			Message: pkg.NotFoundRepositoryMessage,
-		}
+		err = pkg.ErrLockNotHeld
 
 		return err
 	}
@@ -70,13 +265,180 @@ func (r *orderManagementRepository) LockOrderOptimistic(ctx context.Context, par
 	return
 }
 
-func (r *orderManagementRepository) GetNextHighPriorityReadyOrder(ctx context.Context) (res dto.GetNextHighPriorityReadyOrderRepositoryResponse, err error) {
+// CompleteOrder stores the processing result, records the attempt in the attempt
+// history and releases the lease held by params.LockOwner, all in one transaction.
+// The order must still be in one of params.Transition.From; an order cancelled
+// while it was processed is therefore left alone. A retried order is completed
+// as scheduled with params.NextAttemptAt set.
+func (r *orderManagementRepository) CompleteOrder(ctx context.Context, params dto.CompleteOrderRepositoryRequest) (err error) {
+	updates := map[string]interface{}{
+		"lock_owner":       nil,
+		"lock_acquired_at": nil,
+		"lock_expires_at":  nil,
+		"next_attempt_at":  params.NextAttemptAt,
+	}
+	if params.LastError != nil {
+		updates["last_error"] = params.LastError
+	}
+	if params.DeadLetteredAt != nil {
+		updates["dead_lettered_at"] = params.DeadLetteredAt
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		completed, err := transitionOrders(tx, params.Transition, updates, func(query *gorm.DB) *gorm.DB {
+			return query.
+				Where("order_id = ?", params.OrderID).
+				Where("lock_owner = ?", params.LockOwner)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(completed) == 0 {
+			return pkg.ErrLockNotHeld
+		}
+
+		if params.Attempt.Attempt == nil {
+			return nil
+		}
+		return tx.Create(&params.Attempt).Error
+	})
+}
+
+// CancelOrder moves an order in one of params.Transition.From to Cancelled,
+// records the reason and drops any lease on it.
+func (r *orderManagementRepository) CancelOrder(ctx context.Context, params dto.CancelOrderRepositoryRequest) (err error) {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		cancelled, err := transitionOrders(tx, params.Transition, map[string]interface{}{
+			"cancel_reason":    params.CancelReason,
+			"lock_owner":       nil,
+			"lock_acquired_at": nil,
+			"lock_expires_at":  nil,
+			"next_attempt_at":  nil,
+		}, func(query *gorm.DB) *gorm.DB {
+			return query.Where("order_id = ?", params.OrderID)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(cancelled) == 0 {
+			var count int64
+			if err := tx.Table("orders").Where("order_id = ?", params.OrderID).Count(&count).Error; err != nil {
+				return err
+			}
+
+			if count == 0 {
+				return pkg.ErrNotFound
+			}
+
+			return pkg.ErrStatusConflict
+		}
+
+		return nil
+	})
+}
+
+// ListDeadLetters returns dead-lettered orders, most recently dead-lettered first,
+// together with their attempt history.
+func (r *orderManagementRepository) ListDeadLetters(ctx context.Context, params dto.ListDeadLettersRepositoryRequest) (res dto.ListDeadLettersRepositoryResponse, err error) {
+	var orders []dto.BaseOrder
 	err = db.WithContext(ctx).
 		Table("orders").
-		Where("lock = ?", false).
-		Where("status = ?", pkg.StatusOrderManagementPending).
-		Order("priority DESC, created_at ASC").
-		First(&res).Error
+		Where("status = ?", pkg.StatusOrderManagementDeadLettered).
+		Order("dead_lettered_at DESC, order_id ASC").
+		Limit(params.Limit).
+		Offset(params.Offset).
+		Find(&orders).Error
+	if err != nil {
+		return
+	}
+
+	orderIDs := make([]string, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, *order.OrderID)
+	}
+
+	var attempts []dto.BaseOrderAttempt
+	err = db.WithContext(ctx).
+		Where("order_id IN ?", orderIDs).
+		Order("id ASC").
+		Find(&attempts).Error
+	if err != nil {
+		return
+	}
+
+	history := make(map[string][]dto.BaseOrderAttempt, len(orders))
+	for _, attempt := range attempts {
+		history[*attempt.OrderID] = append(history[*attempt.OrderID], attempt)
+	}
+
+	res.DeadLetters = make([]dto.DeadLetterRepositoryResponse, 0, len(orders))
+	for _, order := range orders {
+		res.DeadLetters = append(res.DeadLetters, dto.DeadLetterRepositoryResponse{
+			BaseOrder:      order,
+			AttemptHistory: history[*order.OrderID],
+		})
+	}
+	return
+}
+
+// ReplayDeadLetters returns dead-lettered orders to the queue with a fresh attempt
+// budget. Their attempt history and last error are kept.
+func (r *orderManagementRepository) ReplayDeadLetters(ctx context.Context, params dto.ReplayDeadLettersRepositoryRequest) (res dto.ReplayDeadLettersRepositoryResponse, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		replayed, err := transitionOrders(tx, params.Transition, map[string]interface{}{
+			"attempts":         0,
+			"next_attempt_at":  nil,
+			"dead_lettered_at": nil,
+		}, func(query *gorm.DB) *gorm.DB {
+			if params.All {
+				return query
+			}
+			return query.Where("order_id IN ?", params.OrderIDs)
+		})
+		if err != nil {
+			return err
+		}
+
+		res.OrderIDs = make([]string, 0, len(replayed))
+		for _, order := range replayed {
+			res.OrderIDs = append(res.OrderIDs, *order.OrderID)
+		}
+		return nil
+	})
+	return
+}
+
+// ReleaseOrphanedLocks returns running orders to the queue when their lease has
+// expired, or when it is held by params.InstanceID (a previous run of this instance).
+func (r *orderManagementRepository) ReleaseOrphanedLocks(ctx context.Context, params dto.ReleaseOrphanedLocksRepositoryRequest) (released int64, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, err := transitionOrders(tx, params.Transition, map[string]interface{}{
+			"lock_owner":       nil,
+			"lock_acquired_at": nil,
+			"lock_expires_at":  nil,
+		}, func(query *gorm.DB) *gorm.DB {
+			query = query.Where("lock_owner IS NOT NULL")
+			if params.InstanceID != "" {
+				// Compared as is, since LIKE would take _ and % in the ID as wildcards
+				prefix := params.InstanceID + ":"
+				return query.Where("julianday(lock_expires_at) <= julianday(?) OR substr(lock_owner, 1, ?) = ?", params.Now, utf8.RuneCountInString(prefix), prefix)
+			}
+			return query.Where("julianday(lock_expires_at) <= julianday(?)", params.Now)
+		})
+		released = int64(len(orders))
+		return err
+	})
+	return
+}
+
+// CountPendingOrders counts the orders waiting to be claimed, retries included.
+func (r *orderManagementRepository) CountPendingOrders(ctx context.Context) (count int64, err error) {
+	err = db.WithContext(ctx).
+		Table("orders").
+		Where("status IN ?", []string{pkg.StatusOrderManagementPending, pkg.StatusOrderManagementScheduled}).
+		Count(&count).Error
 	return
 }
 
@@ -102,3 +464,15 @@ func (r *orderManagementRepository) ListAggregateOrderReport(ctx context.Context
 	}
 	return
 }
+
+// effectivePriorityOrder orders orders by their effective priority at now, as
+// computed by pkg.PriorityAging.Effective, and then by age.
+func effectivePriorityOrder(aging pkg.PriorityAging, now time.Time) clause.OrderBy {
+	if aging.Interval <= 0 || aging.Step <= 0 {
+		return clause.OrderBy{Expression: clause.Expr{SQL: "priority DESC, created_at ASC"}}
+	}
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:  "priority + ? * MAX(0, (? - CAST(strftime('%s', created_at) AS INTEGER)) / ?) DESC, created_at ASC",
+		Vars: []interface{}{aging.Step, now.Unix(), max(1, int64(aging.Interval/time.Second))},
+	}}
+}
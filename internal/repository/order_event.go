@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"gorm.io/gorm"
+)
+
+// ListOrderEvents returns the selected order events, oldest first. Event IDs only
+// grow, so they double as the position of an event in the stream of all events.
+func (r *orderManagementRepository) ListOrderEvents(ctx context.Context, params dto.ListOrderEventsRepositoryRequest) (res dto.ListOrderEventsRepositoryResponse, err error) {
+	query := db.WithContext(ctx).Where("id > ?", params.AfterID)
+
+	if params.OrderID != "" {
+		query = query.Where("order_id = ?", params.OrderID)
+	}
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+
+	err = query.Order("id ASC").Find(&res.Events).Error
+	return
+}
+
+// LatestOrderEventID returns the ID of the most recent order event, or 0 if none
+// was recorded yet.
+func (r *orderManagementRepository) LatestOrderEventID(ctx context.Context) (id uint, err error) {
+	err = db.WithContext(ctx).
+		Model(&dto.BaseOrderEvent{}).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&id).Error
+	return
+}
+
+// transitionOrders moves the orders selected by scope that are in one of
+// transition.From to transition.To, applies updates to them and records an event
+// and an outbox message for each order, all within tx. It returns the orders as they were before the
+// change. If an order changed status between being selected and being updated,
+// nothing is changed and a conflict is returned.
+func transitionOrders(tx *gorm.DB, transition dto.StatusTransition, updates map[string]interface{}, scope func(*gorm.DB) *gorm.DB) (orders []dto.BaseOrder, err error) {
+	err = tx.Table("orders").
+		Where("status IN ?", transition.From).
+		Scopes(scope).
+		Find(&orders).Error
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+
+	now := time.Now()
+	updates["status"] = transition.To
+	updates["updated_at"] = now
+	result := tx.Table("orders").
+		Where("id IN ?", ids).
+		Where("status IN ?", transition.From).
+		Updates(updates)
+
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	if result.RowsAffected != int64(len(orders)) {
+		return nil, pkg.ErrStatusConflict
+	}
+
+	events := make([]dto.BaseOrderEvent, 0, len(orders))
+	for _, order := range orders {
+		events = append(events, orderEvent(order.OrderID, order.Status, transition, now))
+	}
+	if err := tx.Create(&events).Error; err != nil {
+		return nil, err
+	}
+	if err := enqueueOutboxMessages(tx, events); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// orderEvent builds the history event of an order moving from one status to
+// transition.To at the given time.
+func orderEvent(orderID *string, from *string, transition dto.StatusTransition, at time.Time) dto.BaseOrderEvent {
+	to := transition.To
+	event := dto.BaseOrderEvent{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   &to,
+		CreatedAt:  &at,
+	}
+	if transition.Actor != "" {
+		event.Actor = &transition.Actor
+	}
+	if transition.Reason != "" {
+		event.Reason = &transition.Reason
+	}
+	return event
+}
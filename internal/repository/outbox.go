@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"gorm.io/gorm"
+)
+
+// ClaimOutboxMessages returns the unpublished messages that are due, oldest first.
+func (r *orderManagementRepository) ClaimOutboxMessages(ctx context.Context, params dto.ClaimOutboxMessagesRepositoryRequest) (res dto.ClaimOutboxMessagesRepositoryResponse, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("published_at IS NULL").
+			Where("next_attempt_at IS NULL OR julianday(next_attempt_at) <= julianday(?)", params.Now).
+			Order("id ASC").
+			Limit(params.Limit).
+			Find(&res.Messages).Error
+		if err != nil || len(res.Messages) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(res.Messages))
+		for _, message := range res.Messages {
+			ids = append(ids, message.ID)
+		}
+
+		return tx.Model(&dto.BaseOutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", params.Until).Error
+	})
+	return
+}
+
+// CompleteOutboxMessage stores the outcome of publishing a message.
+func (r *orderManagementRepository) CompleteOutboxMessage(ctx context.Context, params dto.CompleteOutboxMessageRepositoryRequest) (err error) {
+	result := db.WithContext(ctx).
+		Model(&dto.BaseOutboxMessage{}).
+		Where("id = ?", params.ID).
+		Updates(map[string]interface{}{
+			"attempts":        params.Attempts,
+			"next_attempt_at": params.NextAttemptAt,
+			"last_error":      params.LastError,
+			"published_at":    params.PublishedAt,
+		})
+
+	if err := result.Error; err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return pkg.ErrNotFound
+	}
+	return nil
+}
+
+// DeletePublishedOutboxMessages removes the messages published before
+// params.PublishedBefore.
+func (r *orderManagementRepository) DeletePublishedOutboxMessages(ctx context.Context, params dto.DeletePublishedOutboxMessagesRepositoryRequest) (deleted int64, err error) {
+	result := db.WithContext(ctx).
+		Where("published_at IS NOT NULL").
+		Where("julianday(published_at) < julianday(?)", params.PublishedBefore).
+		Delete(&dto.BaseOutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// enqueueOutboxMessages adds an outbox message for every given order event,
+// within the transaction that recorded them.
+func enqueueOutboxMessages(tx *gorm.DB, events []dto.BaseOrderEvent) error {
+	messages := make([]dto.BaseOutboxMessage, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		encoded := string(payload)
+
+		messages = append(messages, dto.BaseOutboxMessage{
+			Topic:       &pkg.TopicOrderStatusChanged,
+			AggregateID: event.OrderID,
+			Payload:     &encoded,
+			CreatedAt:   event.CreatedAt,
+		})
+	}
+	return tx.CreateInBatches(&messages, createBatchSize).Error
+}
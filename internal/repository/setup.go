@@ -3,6 +3,9 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/seyedmo30/order_management/internal/config"
 	"github.com/seyedmo30/order_management/internal/dto"
@@ -16,6 +19,9 @@ import (
 var (
 	databaseConfig config.DatabaseConfig
 	db             *gorm.DB
+	// keepAlive holds a connection to an in-memory database outside the pool,
+	// so that the database outlives any connection the pool discards.
+	keepAlive *sql.DB
 )
 
 // DB ensures a singleton instance of the database connection.
@@ -23,6 +29,24 @@ func DB() *gorm.DB {
 	return db
 }
 
+// Close closes the database connection.
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDb.Close(); err != nil {
+		return err
+	}
+	if keepAlive != nil {
+		return keepAlive.Close()
+	}
+	return nil
+}
+
 // orderManagementRepository is the structure holding DB configuration for repository operations.
 type orderManagementRepository struct {
 	config config.DatabaseConfig
@@ -31,34 +55,61 @@ type orderManagementRepository struct {
 // Ensure orderManagementRepository implements the Repository interface.
 // var _ interfaces.Repository = (*orderManagementRepository)(nil)
 
-// NewOrderManagementRepository initializes a new orderManagementRepository with the provided configuration.
-func NewOrderManagementRepository(config config.DatabaseConfig) *orderManagementRepository {
+// NewOrderManagementRepository initializes a new orderManagementRepository with the
+// provided configuration. It fails if the database cannot be opened or migrated.
+func NewOrderManagementRepository(config config.DatabaseConfig) (*orderManagementRepository, error) {
 	databaseConfig = config // Store the config for the singleton
 	var err error
-	// Initialize the database only once
 	db, err = SetupDB(databaseConfig)
 	if err != nil {
-		pkg.GetLogger().Error("Error initializing DB: ", err)
-	} // Ensure DB instance is initialized
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
 
-	return &orderManagementRepository{config: config}
+	return &orderManagementRepository{config: config}, nil
 }
 
-// SetupDB configures and returns a GORM DB connection using the provided database config.
-func SetupDB(config config.DatabaseConfig) (*gorm.DB, error) {
+// SetupDB configures and returns a GORM DB connection using the provided database
+// config. The connections it opened are closed again if it fails.
+func SetupDB(config config.DatabaseConfig) (_ *gorm.DB, err error) {
+	var sqlDb *sql.DB
+	defer func() {
+		if err == nil {
+			return
+		}
+		if sqlDb != nil {
+			sqlDb.Close()
+		}
+		if keepAlive != nil {
+			keepAlive.Close()
+			keepAlive = nil
+		}
+	}()
+
+	dsn := buildDSN(config)
+
+	// A shared in-memory database reports table locks to concurrent writers
+	// instead of waiting for them, whatever the busy timeout, and disappears with
+	// its last connection. Its pool is limited to one connection, and another one
+	// is kept open outside the pool for as long as the database is in use.
+	if isInMemoryDSN(dsn) {
+		config.MaxOpenConns, config.MaxIdleConns = 1, 1
+
+		keepAliveDb, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQL connection: %w", err)
+		}
+		keepAlive = keepAliveDb
+		if err := keepAliveDb.Ping(); err != nil {
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
+	}
 
 	// Open the underlying SQL connection (SQLite doesn't need credentials)
-	sqlDb, err := sql.Open("sqlite3", "file::memory:?cache=shared&mode=rw")
+	sqlDb, err = sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQL connection: %w", err)
 	}
 
-	// Set PRAGMA journal_mode to WAL
-	_, err = sqlDb.Exec("PRAGMA journal_mode=WAL;")
-	if err != nil {
-		return nil, fmt.Errorf("failed to set journal_mode to WAL: %w", err)
-	}
-
 	// Ping the database to verify connection
 	if err := sqlDb.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
@@ -75,23 +126,115 @@ func SetupDB(config config.DatabaseConfig) (*gorm.DB, error) {
 	}
 	// Initialize GORM with the SQLite connection
 	db, err := gorm.Open(sqlite.New(sqlite.Config{Conn: sqlDb}), &gorm.Config{
-		PrepareStmt: true,
-		Logger:      logger.Default.LogMode(logMode),
+		PrepareStmt:    true,
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logMode),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open GORM connection: %w", err)
 	}
 
+	if err := registerErrorTranslation(db); err != nil {
+		return nil, fmt.Errorf("failed to register error translation: %w", err)
+	}
+
 	// Set connection pool parameters
-	sqlDb.SetMaxOpenConns(10)
-	sqlDb.SetMaxIdleConns(5)
+	sqlDb.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDb.SetMaxIdleConns(config.MaxIdleConns)
 	sqlDb.SetConnMaxLifetime(0)
 
-	// Auto-migrate the BaseOrder model
-	err = db.AutoMigrate(&dto.BaseOrder{})
+	// Auto-migrate the BaseOrder and BaseOrderAttempt models
+	err = db.AutoMigrate(&dto.BaseOrder{}, &dto.BaseOrderAttempt{}, &dto.BaseOrderEvent{}, &dto.BaseOutboxMessage{}, &dto.BaseWebhookSubscription{}, &dto.BaseWebhookDelivery{}, &dto.BaseIdempotencyKey{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
 
+	if err := migratePriorityLevels(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate priority levels: %w", err)
+	}
+
+	// The boolean lock column was replaced by the lock_owner lease. Dropping it
+	// returns any order it still marks as locked to the queue.
+	if db.Migrator().HasColumn(&dto.BaseOrder{}, "lock") {
+		if err := db.Migrator().DropColumn(&dto.BaseOrder{}, "lock"); err != nil {
+			return nil, fmt.Errorf("failed to drop legacy lock column: %w", err)
+		}
+	}
+
+	if err := migrateLifecycleStatuses(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate order statuses: %w", err)
+	}
+
 	return db, nil
 }
+
+// migrateLifecycleStatuses moves orders stored before the Running and Scheduled
+// statuses existed, when both were kept as Pending, into their lifecycle status.
+func migrateLifecycleStatuses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("orders").
+			Where("status = ?", pkg.StatusOrderManagementPending).
+			Where("lock_owner IS NOT NULL").
+			Update("status", pkg.StatusOrderManagementRunning).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("orders").
+			Where("status = ?", pkg.StatusOrderManagementPending).
+			Where("next_attempt_at IS NOT NULL").
+			Update("status", pkg.StatusOrderManagementScheduled).Error
+	})
+}
+
+// migratePriorityLevels converts priorities stored by name, as they were before
+// the priority column held levels, into their numeric level.
+func migratePriorityLevels(db *gorm.DB) error {
+	levels := "CASE priority"
+	args := make([]interface{}, 0, len(pkg.PriorityLevels)*2+1)
+	for name, level := range pkg.PriorityLevels {
+		levels += " WHEN ? THEN ?"
+		args = append(args, name, level)
+	}
+	levels += " ELSE ? END"
+	args = append(args, pkg.PriorityLevels[pkg.PriorityOrderManagementNormal])
+
+	return db.Table("orders").
+		Where("typeof(priority) = ?", "text").
+		Update("priority", gorm.Expr(levels, args...)).Error
+}
+
+// buildDSN appends the connection pragmas to the configured DSN. The pragmas are
+// passed as driver parameters rather than executed once, so that every pooled
+// connection gets them. In-memory databases keep their default journal mode.
+func buildDSN(config config.DatabaseConfig) string {
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = "file::memory:?cache=shared&mode=rw"
+	}
+
+	params := url.Values{}
+	if config.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.Itoa(config.BusyTimeout))
+	}
+	if config.Synchronous != "" {
+		params.Set("_synchronous", config.Synchronous)
+	}
+	if !isInMemoryDSN(dsn) {
+		params.Set("_journal_mode", "WAL")
+	}
+	// Take the write lock when a transaction begins instead of upgrading a read
+	// lock later, which would fail with SQLITE_BUSY under concurrent writers.
+	params.Set("_txlock", "immediate")
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + params.Encode()
+}
+
+// isInMemoryDSN reports whether the DSN points at an in-memory database.
+func isInMemoryDSN(dsn string) bool {
+	return strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
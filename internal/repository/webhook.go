@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *orderManagementRepository) CreateWebhookSubscription(ctx context.Context, params dto.CreateWebhookSubscriptionRepositoryRequest) (err error) {
+	return db.WithContext(ctx).Create(&params.BaseWebhookSubscription).Error
+}
+
+func (r *orderManagementRepository) ListWebhookSubscriptions(ctx context.Context) (res dto.ListWebhookSubscriptionsRepositoryResponse, err error) {
+	err = db.WithContext(ctx).
+		Order("created_at ASC, id ASC").
+		Find(&res.Subscriptions).Error
+	return
+}
+
+// DeleteWebhookSubscription removes a subscription together with the deliveries
+// not sent to it yet. Its delivery log is kept.
+func (r *orderManagementRepository) DeleteWebhookSubscription(ctx context.Context, subscriptionID string) (err error) {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", subscriptionID).Delete(&dto.BaseWebhookSubscription{})
+
+		if err := result.Error; err != nil {
+			return err
+		}
+
+		if result.RowsAffected == 0 {
+			return pkg.ErrNotFound
+		}
+
+		return tx.
+			Where("subscription_id = ?", subscriptionID).
+			Where("status = ?", pkg.StatusWebhookDeliveryPending).
+			Delete(&dto.BaseWebhookDelivery{}).Error
+	})
+}
+
+// ListWebhookDeliveries returns the delivery log of a subscription, newest first.
+func (r *orderManagementRepository) ListWebhookDeliveries(ctx context.Context, params dto.ListWebhookDeliveriesRepositoryRequest) (res dto.ListWebhookDeliveriesRepositoryResponse, err error) {
+	err = db.WithContext(ctx).
+		Where("subscription_id = ?", params.SubscriptionID).
+		Order("id DESC").
+		Limit(params.Limit).
+		Offset(params.Offset).
+		Find(&res.Deliveries).Error
+	return
+}
+
+// ClaimWebhookDeliveries returns the pending deliveries that are due, oldest
+// first, with their subscription and event.
+func (r *orderManagementRepository) ClaimWebhookDeliveries(ctx context.Context, params dto.ClaimWebhookDeliveriesRepositoryRequest) (res dto.ClaimWebhookDeliveriesRepositoryResponse, err error) {
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deliveries []dto.BaseWebhookDelivery
+		err := tx.
+			Where("status = ?", pkg.StatusWebhookDeliveryPending).
+			Where("next_attempt_at IS NULL OR julianday(next_attempt_at) <= julianday(?)", params.Now).
+			Order("id ASC").
+			Limit(params.Limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(deliveries))
+		subscriptionIDs := make([]string, 0, len(deliveries))
+		eventIDs := make([]uint, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+			subscriptionIDs = append(subscriptionIDs, *delivery.SubscriptionID)
+			eventIDs = append(eventIDs, *delivery.EventID)
+		}
+
+		err = tx.Model(&dto.BaseWebhookDelivery{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"next_attempt_at": params.Until,
+				"updated_at":      params.Now,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		var subscriptions []dto.BaseWebhookSubscription
+		if err := tx.Where("id IN ?", subscriptionIDs).Find(&subscriptions).Error; err != nil {
+			return err
+		}
+		var events []dto.BaseOrderEvent
+		if err := tx.Where("id IN ?", eventIDs).Find(&events).Error; err != nil {
+			return err
+		}
+
+		subscriptionsByID := make(map[string]dto.BaseWebhookSubscription, len(subscriptions))
+		for _, subscription := range subscriptions {
+			subscriptionsByID[subscription.ID] = subscription
+		}
+		eventsByID := make(map[uint]dto.BaseOrderEvent, len(events))
+		for _, event := range events {
+			eventsByID[event.ID] = event
+		}
+
+		res.Deliveries = make([]dto.WebhookDeliveryRepositoryResponse, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			res.Deliveries = append(res.Deliveries, dto.WebhookDeliveryRepositoryResponse{
+				BaseWebhookDelivery: delivery,
+				Subscription:        subscriptionsByID[*delivery.SubscriptionID],
+				Event:               eventsByID[*delivery.EventID],
+			})
+		}
+		return nil
+	})
+	return
+}
+
+// CompleteWebhookDelivery stores the outcome of a delivery attempt.
+func (r *orderManagementRepository) CompleteWebhookDelivery(ctx context.Context, params dto.CompleteWebhookDeliveryRepositoryRequest) (err error) {
+	result := db.WithContext(ctx).
+		Model(&dto.BaseWebhookDelivery{}).
+		Where("id = ?", params.ID).
+		Updates(map[string]interface{}{
+			"status":          params.Status,
+			"attempts":        params.Attempts,
+			"next_attempt_at": params.NextAttemptAt,
+			"response_code":   params.ResponseCode,
+			"last_error":      params.LastError,
+			"delivered_at":    params.DeliveredAt,
+			"updated_at":      time.Now(),
+		})
+
+	if err := result.Error; err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return pkg.ErrNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries adds a delivery of the event for every subscription
+// that receives it. Enqueueing an event again adds no further deliveries.
+func (r *orderManagementRepository) EnqueueWebhookDeliveries(ctx context.Context, event dto.BaseOrderEvent) (err error) {
+	var subscriptions []dto.BaseWebhookSubscription
+	if err := db.WithContext(ctx).Find(&subscriptions).Error; err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []dto.BaseWebhookDelivery
+	for i := range subscriptions {
+		if !subscribedTo(subscriptions[i], *event.ToStatus) {
+			continue
+		}
+		deliveries = append(deliveries, dto.BaseWebhookDelivery{
+			SubscriptionID: &subscriptions[i].ID,
+			EventID:        &event.ID,
+			OrderID:        event.OrderID,
+			Status:         &pkg.StatusWebhookDeliveryPending,
+			CreatedAt:      &now,
+			UpdatedAt:      &now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&deliveries).Error
+}
+
+// subscribedTo reports whether a subscription receives events moving an order to
+// the given status.
+func subscribedTo(subscription dto.BaseWebhookSubscription, status string) bool {
+	if subscription.Statuses == nil || *subscription.Statuses == "" {
+		return true
+	}
+	for _, subscribed := range strings.Split(*subscription.Statuses, ",") {
+		if subscribed == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+)
+
+// fileSink appends outbox messages to a file, one JSON object per line.
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *fileSink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+// Publish appends the message and syncs the file, so that a published message
+// survives a crash.
+func (s *fileSink) Publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
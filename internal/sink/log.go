@@ -0,0 +1,24 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// logSink writes outbox messages to the service log.
+type logSink struct{}
+
+func NewLogSink() *logSink {
+	return &logSink{}
+}
+
+func (s *logSink) Name() string {
+	return "log"
+}
+
+func (s *logSink) Publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	pkg.GetLogger().Info("Outbox message", "id", message.ID, "topic", message.Topic, "aggregateID", message.AggregateID, "payload", message.Payload)
+	return nil
+}
@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"context"
+	"sync"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+)
+
+// MemorySink hands outbox messages to the in-process subscribers listening at
+// the time. Publish waits until every subscriber has taken the message or
+// unsubscribed.
+type MemorySink struct {
+	mu          sync.Mutex
+	subscribers map[*memorySubscriber]struct{}
+}
+
+type memorySubscriber struct {
+	messages chan dto.BaseOutboxMessage
+	done     chan struct{} // Closed on unsubscribe
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{subscribers: make(map[*memorySubscriber]struct{})}
+}
+
+func (s *MemorySink) Name() string {
+	return "memory"
+}
+
+// Subscribe returns a channel receiving every message published until unsubscribe
+// is called. The subscriber must keep reading it, as it holds up the relay.
+func (s *MemorySink) Subscribe() (messages <-chan dto.BaseOutboxMessage, unsubscribe func()) {
+	subscriber := &memorySubscriber{
+		messages: make(chan dto.BaseOutboxMessage),
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.subscribers[subscriber] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return subscriber.messages, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, subscriber)
+			s.mu.Unlock()
+			close(subscriber.done)
+		})
+	}
+}
+
+func (s *MemorySink) Publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	s.mu.Lock()
+	subscribers := make([]*memorySubscriber, 0, len(s.subscribers))
+	for subscriber := range s.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	s.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber.messages <- message:
+		case <-subscriber.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+)
+
+// FromConfig builds the outbox sinks named in config.OutboxSinks.
+func FromConfig(config config.App, webhookRepo interfaces.WebhookRepository) ([]interfaces.OutboxSink, error) {
+	var sinks []interfaces.OutboxSink
+	for _, name := range strings.Split(config.OutboxSinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "log":
+			sinks = append(sinks, NewLogSink())
+		case "file":
+			sinks = append(sinks, NewFileSink(config.OutboxFilePath))
+		case "webhook":
+			sinks = append(sinks, NewWebhookSink(webhookRepo))
+		case "memory":
+			sinks = append(sinks, NewMemorySink())
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,36 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// webhookSink queues order events for delivery to the webhooks that receive them.
+// Queueing an event twice delivers it only once.
+type webhookSink struct {
+	repo interfaces.WebhookRepository
+}
+
+func NewWebhookSink(repo interfaces.WebhookRepository) *webhookSink {
+	return &webhookSink{repo: repo}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	if *message.Topic != pkg.TopicOrderStatusChanged {
+		return nil
+	}
+
+	var event dto.BaseOrderEvent
+	if err := json.Unmarshal([]byte(*message.Payload), &event); err != nil {
+		return err
+	}
+	return s.repo.EnqueueWebhookDeliveries(ctx, event)
+}
@@ -0,0 +1,271 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeClock only passes time when ticked. The loop it drives hands over every
+// wait, so that a tick returns once the loop is waiting again.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiting chan chan time.Time
+	next    chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), waiting: make(chan chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	wait := make(chan time.Time, 1)
+	c.waiting <- wait
+	return wait
+}
+
+// hold returns once the loop waits for the clock.
+func (c *fakeClock) hold() {
+	if c.next == nil {
+		c.next = <-c.waiting
+	}
+}
+
+// tick passes d, ends the wait of the loop and returns once it waits again.
+func (c *fakeClock) tick(d time.Duration) {
+	c.hold()
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	c.next <- c.Now()
+	c.next = <-c.waiting
+}
+
+// fakeWorkerPool records how it is resized.
+type fakeWorkerPool struct {
+	mu      sync.Mutex
+	pool    dto.WorkerPoolUsecaseResponse
+	resizes []dto.ResizeWorkersUsecaseRequest
+}
+
+func (f *fakeWorkerPool) GetWorkerPool(ctx context.Context) (dto.WorkerPoolUsecaseResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pool, nil
+}
+
+func (f *fakeWorkerPool) ResizeWorkers(ctx context.Context, req dto.ResizeWorkersUsecaseRequest) (dto.WorkerPoolUsecaseResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pool.Size = req.Size
+	f.resizes = append(f.resizes, req)
+	return f.pool, nil
+}
+
+func (f *fakeWorkerPool) update(update func(pool *dto.WorkerPoolUsecaseResponse)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update(&f.pool)
+}
+
+func (f *fakeWorkerPool) lastResize() (dto.ResizeWorkersUsecaseRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.resizes) == 0 {
+		return dto.ResizeWorkersUsecaseRequest{}, false
+	}
+	return f.resizes[len(f.resizes)-1], true
+}
+
+// AutoscalerTestSuite drives the autoscaler with a fake clock and worker pool.
+// Every test starts with a running pool of two workers and without orders in an
+// in-memory database of its own.
+type AutoscalerTestSuite struct {
+	cfg               config.App
+	cancel            context.CancelFunc
+	done              chan struct{}
+	clock             *fakeClock
+	pool              *fakeWorkerPool
+	repositoryService interfaces.OrderRepository
+
+	suite.Suite
+}
+
+func (p *AutoscalerTestSuite) SetupSuite() {
+	p.cfg = config.App{
+		DatabaseConfig: config.DatabaseConfig{
+			LogLevel:     "ERROR",
+			DSN:          "file:autoscaler?mode=memory&cache=shared",
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		},
+		ServiceConfig: config.ServiceConfig{
+			OrderProcessTimeout:    5,
+			AutoscaleMinWorkers:    1,
+			AutoscaleMaxWorkers:    8,
+			AutoscaleInterval:      10,
+			AutoscaleMaxContention: 10,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(p.cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+}
+
+func (p *AutoscalerTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+
+	p.clock = newFakeClock()
+	p.pool = &fakeWorkerPool{pool: dto.WorkerPoolUsecaseResponse{State: pkg.StatusWorkerPoolRunning, Size: 2}}
+	autoscaler := usecase.NewAutoscaler(p.cfg, p.repositoryService, p.pool, p.clock)
+
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		autoscaler.AutoscaleWorkers(ctx)
+	}()
+	p.clock.hold()
+}
+
+func (p *AutoscalerTestSuite) TearDownTest() {
+	p.cancel()
+	<-p.done
+}
+
+func TestAutoscaler(t *testing.T) {
+	suite.Run(t, new(AutoscalerTestSuite))
+}
+
+func (p *AutoscalerTestSuite) createOrders(count int) {
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime := 1
+	orders := make([]dto.BaseOrder, count)
+	for i := range orders {
+		orderID := fmt.Sprintf("autoscale-%03d", i)
+		orders[i] = dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &pkg.StatusOrderManagementPending,
+			ProcessingTime: &processingTime,
+		}
+	}
+	_, err := p.repositoryService.CreateOrders(context.Background(), dto.CreateOrdersRepositoryRequest{Orders: orders})
+	p.NoError(err)
+}
+
+// processed records orders processed during the current interval.
+func (p *AutoscalerTestSuite) processed(count int, each time.Duration) {
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) {
+		pool.Stats.Claims += int64(count)
+		pool.Stats.Processed += int64(count)
+		pool.Stats.ProcessingTime += time.Duration(count) * each
+	})
+}
+
+func (p *AutoscalerTestSuite) size() int {
+	pool, err := p.pool.GetWorkerPool(context.Background())
+	p.NoError(err)
+	return pool.Size
+}
+
+func (p *AutoscalerTestSuite) TestScaleUpToDrainBacklog() {
+	// 30 orders at 2s each take 6 workers to drain within the 10s interval
+	p.createOrders(30)
+	p.processed(10, 2*time.Second)
+	p.clock.tick(10 * time.Second)
+
+	resize, ok := p.pool.lastResize()
+	p.True(ok)
+	p.Equal(6, resize.Size)
+	p.Equal("autoscaler", resize.Actor)
+	p.Equal("30 pending orders at 2s each", resize.Reason)
+
+	// The backlog no longer grows the pool, nor shrinks it while being drained
+	p.clock.tick(10 * time.Second)
+	p.Equal(6, p.size())
+	p.Len(p.pool.resizes, 1)
+}
+
+func (p *AutoscalerTestSuite) TestScaleUpUntilMaxWorkers() {
+	// Before orders were processed, they are assumed to take the full 5s timeout
+	p.createOrders(100)
+	p.clock.tick(10 * time.Second)
+	p.Equal(8, p.size())
+}
+
+func (p *AutoscalerTestSuite) TestScaleDownOnContention() {
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) { pool.Size = 6 })
+	p.createOrders(30)
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) {
+		pool.Stats.Claims += 20
+		pool.Stats.Contended += 5
+	})
+	p.clock.tick(10 * time.Second)
+
+	resize, ok := p.pool.lastResize()
+	p.True(ok)
+	p.Equal(5, resize.Size)
+	p.Equal("5 of 20 claims hit database contention", resize.Reason)
+
+	// Contention within the limit lets the backlog grow the pool again
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) {
+		pool.Stats.Claims += 20
+		pool.Stats.Contended += 2
+	})
+	p.clock.tick(10 * time.Second)
+	p.Equal(8, p.size())
+}
+
+func (p *AutoscalerTestSuite) TestScaleDownWhenIdle() {
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) {
+		pool.Size = 8
+		pool.Busy = 2
+	})
+
+	// Half of the idle workers are retired per interval, down to the busy ones
+	var sizes []int
+	for i := 0; i < 4; i++ {
+		p.clock.tick(10 * time.Second)
+		sizes = append(sizes, p.size())
+	}
+	p.Equal([]int{5, 4, 3, 2}, sizes)
+
+	// and to the minimum once none is busy
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) { pool.Busy = 0 })
+	p.clock.tick(10 * time.Second)
+	p.clock.tick(10 * time.Second)
+	p.Equal(1, p.size())
+}
+
+func (p *AutoscalerTestSuite) TestLeavePausedPoolAlone() {
+	p.pool.update(func(pool *dto.WorkerPoolUsecaseResponse) { pool.State = pkg.StatusWorkerPoolPaused })
+	p.createOrders(30)
+	p.clock.tick(10 * time.Second)
+
+	_, ok := p.pool.lastResize()
+	p.False(ok)
+}
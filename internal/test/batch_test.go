@@ -0,0 +1,237 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// BatchTestSuite creates orders in batches, through the use case and the API.
+// Every test starts without orders in an in-memory database of its own.
+type BatchTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+	server            *echo.Echo
+
+	suite.Suite
+}
+
+func (p *BatchTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: config.DatabaseConfig{
+			LogLevel:     "ERROR",
+			DSN:          "file:batch?mode=memory&cache=shared",
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		},
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 2,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.server = newServer(p.orderService, usecase.NewWebhookUseCase(cfg, repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *BatchTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func (p *BatchTestSuite) TestCreateOrders() {
+	_, err := p.orderService.CreateOrder(p.ctx, dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+		OrderID:        "batch-00",
+		Priority:       pkg.PriorityOrderManagementNormal,
+		ProcessingTime: 1,
+	}})
+	p.NoError(err)
+
+	res, err := p.orderService.CreateOrders(p.ctx, dto.CreateOrdersUsecaseRequest{Orders: []dto.BaseCreateOrderRequest{
+		{OrderID: "batch-01", Priority: pkg.PriorityOrderManagementHigh, ProcessingTime: 1},
+		{OrderID: "batch-00", Priority: pkg.PriorityOrderManagementHigh, ProcessingTime: 1},
+		{OrderID: "batch-02", Priority: "Urgent", ProcessingTime: 1},
+		{OrderID: "batch-01", Priority: pkg.PriorityOrderManagementLow, ProcessingTime: 1},
+		{OrderID: "batch-03", Priority: pkg.PriorityOrderManagementBulk, ProcessingTime: 2},
+	}})
+	p.NoError(err)
+
+	statuses := make([]string, 0, len(res.Results))
+	for _, result := range res.Results {
+		statuses = append(statuses, result.Status)
+	}
+	p.Equal([]string{
+		pkg.StatusBatchItemCreated,
+		pkg.StatusBatchItemDuplicate,
+		pkg.StatusBatchItemInvalid,
+		pkg.StatusBatchItemDuplicate,
+		pkg.StatusBatchItemCreated,
+	}, statuses)
+
+	// The earlier order with a repeated ID is unchanged
+	order, err := p.repositoryService.GetOrderByID(p.ctx, "batch-00")
+	p.NoError(err)
+	p.Equal(pkg.PriorityLevels[pkg.PriorityOrderManagementNormal], *order.Priority)
+
+	order, err = p.repositoryService.GetOrderByID(p.ctx, "batch-03")
+	p.NoError(err)
+	p.Equal(pkg.StatusOrderManagementPending, *order.Status)
+	p.Equal(2, *order.ProcessingTime)
+
+	// Every order created records its creation, which is queued in the outbox
+	for _, orderID := range []string{"batch-01", "batch-03"} {
+		events, err := p.repositoryService.ListOrderEvents(p.ctx, dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+		p.NoError(err)
+		p.Len(events.Events, 1)
+		p.Equal(pkg.StatusOrderManagementPending, *events.Events[0].ToStatus)
+	}
+
+	var messages int64
+	p.NoError(repository.DB().Model(&dto.BaseOutboxMessage{}).Count(&messages).Error)
+	p.Equal(int64(3), messages)
+}
+
+func (p *BatchTestSuite) TestCreateOrdersAllRejected() {
+	res, err := p.orderService.CreateOrders(p.ctx, dto.CreateOrdersUsecaseRequest{Orders: []dto.BaseCreateOrderRequest{
+		{OrderID: "batch-04", Priority: "Urgent", ProcessingTime: 1},
+	}})
+	p.NoError(err)
+	p.Len(res.Results, 1)
+	p.Equal(pkg.StatusBatchItemInvalid, res.Results[0].Status)
+
+	_, err = p.repositoryService.GetOrderByID(p.ctx, "batch-04")
+	p.True(isNotFound(err))
+}
+
+// batchResponse is the response to a batch submission.
+type batchResponse struct {
+	Created  int `json:"created"`
+	Rejected int `json:"rejected"`
+	Results  []struct {
+		Index   int    `json:"index"`
+		OrderID string `json:"order_id"`
+		Status  string `json:"status"`
+		Error   string `json:"error"`
+	} `json:"results"`
+}
+
+// postBatch submits a batch with the given content type.
+func (p *BatchTestSuite) postBatch(contentType string, body string) (int, batchResponse) {
+	rec := serve(p.server, http.MethodPost, "/api/v1/orders/batch", strings.NewReader(body), echo.HeaderContentType, contentType)
+
+	var res batchResponse
+	if rec.Code == http.StatusAccepted {
+		p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return rec.Code, res
+}
+
+func (p *BatchTestSuite) TestPostJSONBatch() {
+	code, res := p.postBatch(echo.MIMEApplicationJSON, `[
+		{"order_id": "batch-10", "priority": "High", "processing_time": 1},
+		{"order_id": "batch-11", "priority": "Urgent", "processing_time": 1},
+		"batch-12",
+		{"order_id": "batch-13", "priority": "Low", "processing_time": 0}
+	]`)
+	p.Equal(http.StatusAccepted, code)
+	p.Equal(1, res.Created)
+	p.Equal(3, res.Rejected)
+	p.Require().Len(res.Results, 4)
+
+	for i, result := range res.Results {
+		p.Equal(i, result.Index)
+	}
+	p.Equal("batch-10", res.Results[0].OrderID)
+	p.Equal(pkg.StatusBatchItemCreated, res.Results[0].Status)
+	p.Empty(res.Results[0].Error)
+
+	// Every order is validated on its own
+	p.Equal("batch-11", res.Results[1].OrderID)
+	p.Equal(pkg.StatusBatchItemInvalid, res.Results[1].Status)
+	p.Contains(res.Results[1].Error, "Priority")
+	p.Equal(pkg.StatusBatchItemInvalid, res.Results[2].Status)
+	p.Contains(res.Results[2].Error, "invalid order")
+	p.Equal("batch-13", res.Results[3].OrderID)
+	p.Equal(pkg.StatusBatchItemInvalid, res.Results[3].Status)
+	p.Contains(res.Results[3].Error, "ProcessingTime")
+
+	_, err := p.repositoryService.GetOrderByID(p.ctx, "batch-10")
+	p.NoError(err)
+	_, err = p.repositoryService.GetOrderByID(p.ctx, "batch-11")
+	p.True(isNotFound(err))
+}
+
+func (p *BatchTestSuite) TestPostNDJSONBatch() {
+	code, res := p.postBatch("application/x-ndjson", `{"order_id": "batch-20", "priority": "Normal", "processing_time": 1}
+
+{"order_id": "batch-21", "priority": "Bulk", "processing_time": 2}
+{"order_id": "batch-20", "priority": "High", "processing_time": 1}
+`)
+	p.Equal(http.StatusAccepted, code)
+	p.Equal(2, res.Created)
+	p.Equal(1, res.Rejected)
+
+	statuses := make([]string, 0, len(res.Results))
+	for _, result := range res.Results {
+		statuses = append(statuses, result.Status)
+	}
+	p.Equal([]string{pkg.StatusBatchItemCreated, pkg.StatusBatchItemCreated, pkg.StatusBatchItemDuplicate}, statuses)
+
+	order, err := p.repositoryService.GetOrderByID(p.ctx, "batch-21")
+	p.NoError(err)
+	p.Equal(2, *order.ProcessingTime)
+}
+
+func (p *BatchTestSuite) TestPostEmptyBatch() {
+	code, _ := p.postBatch(echo.MIMEApplicationJSON, `[]`)
+	p.Equal(http.StatusBadRequest, code)
+
+	code, _ = p.postBatch("application/x-ndjson", "\n\n")
+	p.Equal(http.StatusBadRequest, code)
+}
+
+func (p *BatchTestSuite) TestPostMalformedBatch() {
+	code, _ := p.postBatch(echo.MIMEApplicationJSON, `{"order_id": "batch-30", "priority": "High", "processing_time": 1}`)
+	p.Equal(http.StatusBadRequest, code)
+
+	code, _ = p.postBatch(echo.MIMEApplicationJSON, `[{"order_id": "batch-31"`)
+	p.Equal(http.StatusBadRequest, code)
+}
+
+func (p *BatchTestSuite) TestPostBatchTooLarge() {
+	orders := make([]string, 0, 1001)
+	for i := 0; i < 1001; i++ {
+		orders = append(orders, fmt.Sprintf(`{"order_id": "batch-large-%04d", "priority": "Normal", "processing_time": 1}`, i))
+	}
+
+	code, _ := p.postBatch(echo.MIMEApplicationJSON, "["+strings.Join(orders, ",")+"]")
+	p.Equal(http.StatusRequestEntityTooLarge, code)
+	code, _ = p.postBatch("application/x-ndjson", strings.Join(orders, "\n"))
+	p.Equal(http.StatusRequestEntityTooLarge, code)
+
+	// A batch of the largest size is accepted
+	code, res := p.postBatch(echo.MIMEApplicationJSON, "["+strings.Join(orders[:1000], ",")+"]")
+	p.Equal(http.StatusAccepted, code)
+	p.Equal(1000, res.Created)
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// CancelTestSuite cancels orders while a single worker processes them. Every
+// test queues its orders before starting the worker, which wakes it for none of
+// them; once idle, the worker only polls for orders every 30 seconds.
+type CancelTestSuite struct {
+	workerSuite
+	gate chan struct{}
+}
+
+func (p *CancelTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "cancel",
+		WorkerCount:         1,
+		WorkerPollInterval:  30,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+	}, gatedProcess{gate: p.gate})
+}
+
+func (p *CancelTestSuite) SetupTest() {
+	p.deleteOrders()
+}
+
+func TestCancel(t *testing.T) {
+	suite.Run(t, new(CancelTestSuite))
+}
+
+func (p *CancelTestSuite) TestCancelAbortsProcessing() {
+	p.queueOrder("cancel-01", pkg.PriorityOrderManagementNormal)
+	p.startWorkers()
+	p.waitStatus("cancel-01", pkg.StatusOrderManagementRunning)
+
+	err := p.orderService.CancelOrder(context.Background(), dto.CancelOrderUsecaseRequest{
+		OrderID: "cancel-01",
+		Reason:  "customer request",
+		Actor:   "test",
+	})
+	p.NoError(err)
+
+	// The processing returns without its gate being opened
+	p.Eventually(func() bool {
+		res, err := p.orderService.GetWorkerPool(context.Background())
+		return err == nil && res.Busy == 0 && res.Stats.Processed == 1
+	}, time.Second, 10*time.Millisecond)
+
+	order := p.order("cancel-01")
+	p.Equal(pkg.StatusOrderManagementCancelled, *order.Status)
+	p.Equal("customer request", *order.CancelReason)
+	p.Nil(order.LockOwner)
+}
+
+func (p *CancelTestSuite) TestCancelAfterProcessingEnded() {
+	p.queueOrder("cancel-02", pkg.PriorityOrderManagementNormal)
+	p.queueOrder("queued", pkg.PriorityOrderManagementNormal)
+	p.startWorkers()
+	p.waitStatus("cancel-02", pkg.StatusOrderManagementRunning)
+
+	// The order is cancelled in the repository just before its processing ends,
+	// too late for the processing to be aborted
+	orderID, reason := "cancel-02", "customer request"
+	err := p.repositoryService.CancelOrder(context.Background(), dto.CancelOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{OrderID: &orderID, CancelReason: &reason},
+		Transition: dto.StatusTransition{
+			From:   []string{pkg.StatusOrderManagementRunning},
+			To:     pkg.StatusOrderManagementCancelled,
+			Actor:  "test",
+			Reason: reason,
+		},
+	})
+	p.NoError(err)
+	p.gate <- struct{}{}
+
+	// The worker keeps the cancellation and claims the next order right away
+	p.waitStatus("queued", pkg.StatusOrderManagementRunning)
+	p.Equal(pkg.StatusOrderManagementCancelled, p.status("cancel-02"))
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// fileDatabaseConfig returns the configuration of a database file of its own,
+// removed along with the temporary directory of t. Unlike a shared in-memory
+// database, it waits on locks held by concurrent writers and outlives every
+// pooled connection.
+func fileDatabaseConfig(t *testing.T) config.DatabaseConfig {
+	return config.DatabaseConfig{
+		LogLevel:     "ERROR",
+		DSN:          "file:" + filepath.Join(t.TempDir(), "order_management.db"),
+		BusyTimeout:  5000,
+		MaxOpenConns: 10,
+		MaxIdleConns: 5,
+	}
+}
+
+// DatabaseTestSuite opens and reopens the database. Every test has a database
+// file of its own.
+type DatabaseTestSuite struct {
+	suite.Suite
+}
+
+func TestDatabase(t *testing.T) {
+	suite.Run(t, new(DatabaseTestSuite))
+}
+
+func (p *DatabaseTestSuite) TestOrdersOutliveTheDatabaseConnection() {
+	ctx := context.Background()
+	cfg := fileDatabaseConfig(p.T())
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg)
+	p.Require().NoError(err)
+
+	nextAttemptAt := time.Now().Add(time.Minute)
+	orders := []struct {
+		orderID  string
+		priority string
+		status   string
+	}{
+		{"durable-01", pkg.PriorityOrderManagementHigh, pkg.StatusOrderManagementPending},
+		{"durable-02", pkg.PriorityOrderManagementLow, pkg.StatusOrderManagementScheduled},
+		{"durable-03", pkg.PriorityOrderManagementCritical, pkg.StatusOrderManagementProcessed},
+	}
+	for _, order := range orders {
+		order := order
+		priority := pkg.PriorityLevels[order.priority]
+		processingTime := 1
+		req := dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             order.orderID,
+			OrderID:        &order.orderID,
+			Priority:       &priority,
+			Status:         &order.status,
+			ProcessingTime: &processingTime,
+		}}
+		if order.status == pkg.StatusOrderManagementScheduled {
+			req.NextAttemptAt = &nextAttemptAt
+		}
+		p.Require().NoError(repositoryService.CreateOrder(ctx, req))
+	}
+	p.Require().NoError(repository.Close())
+
+	repositoryService, err = repository.NewOrderManagementRepository(cfg)
+	p.Require().NoError(err)
+	defer func() { p.NoError(repository.Close()) }()
+
+	for _, order := range orders {
+		res, err := repositoryService.GetOrderByID(ctx, order.orderID)
+		p.Require().NoError(err, order.orderID)
+		p.Equal(pkg.PriorityLevels[order.priority], *res.Priority, order.orderID)
+		p.Equal(order.status, *res.Status, order.orderID)
+	}
+}
+
+func (p *DatabaseTestSuite) TestUnwritablePathFails() {
+	cfg := fileDatabaseConfig(p.T())
+	cfg.DSN = "file:" + filepath.Join(p.T().TempDir(), "missing", "order_management.db")
+
+	_, err := repository.NewOrderManagementRepository(cfg)
+	p.Error(err)
+}
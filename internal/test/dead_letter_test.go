@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// DeadLetterTestSuite replays dead-lettered orders. Every test starts with three
+// dead letters in a database file of its own.
+type DeadLetterTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+
+	suite.Suite
+}
+
+func (p *DeadLetterTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.ctx = context.Background()
+}
+
+func (p *DeadLetterTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *DeadLetterTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime := 1
+	attempts := 3
+	for _, orderID := range []string{"dead-letter-01", "dead-letter-02", "dead-letter-03"} {
+		orderID := orderID
+		deadLetteredAt := time.Now()
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &pkg.StatusOrderManagementDeadLettered,
+			ProcessingTime: &processingTime,
+			Attempts:       &attempts,
+			DeadLetteredAt: &deadLetteredAt,
+		}})
+		p.NoError(err)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	suite.Run(t, new(DeadLetterTestSuite))
+}
+
+// deadLetters returns the IDs of the orders still dead-lettered.
+func (p *DeadLetterTestSuite) deadLetters() []string {
+	res, err := p.repositoryService.ListDeadLetters(p.ctx, dto.ListDeadLettersRepositoryRequest{Limit: 10})
+	p.NoError(err)
+
+	orderIDs := make([]string, 0, len(res.DeadLetters))
+	for _, deadLetter := range res.DeadLetters {
+		orderIDs = append(orderIDs, *deadLetter.OrderID)
+	}
+	return orderIDs
+}
+
+func (p *DeadLetterTestSuite) TestReplaySelected() {
+	res, err := p.orderService.ReplayDeadLetters(p.ctx, dto.ReplayDeadLettersUsecaseRequest{
+		OrderIDs: []string{"dead-letter-01", "dead-letter-03"},
+		Actor:    "test",
+	})
+	p.NoError(err)
+	p.ElementsMatch([]string{"dead-letter-01", "dead-letter-03"}, res.OrderIDs)
+	p.Equal([]string{"dead-letter-02"}, p.deadLetters())
+}
+
+func (p *DeadLetterTestSuite) TestReplayAll() {
+	res, err := p.orderService.ReplayDeadLetters(p.ctx, dto.ReplayDeadLettersUsecaseRequest{All: true, Actor: "test"})
+	p.NoError(err)
+	p.Len(res.OrderIDs, 3)
+	p.Empty(p.deadLetters())
+}
+
+func (p *DeadLetterTestSuite) TestReplayRequiresSelection() {
+	for _, req := range []dto.ReplayDeadLettersUsecaseRequest{
+		{Actor: "test"},
+		{OrderIDs: []string{}, Actor: "test"},
+		{OrderIDs: []string{"dead-letter-01"}, All: true, Actor: "test"},
+	} {
+		_, err := p.orderService.ReplayDeadLetters(p.ctx, req)
+		p.ErrorIs(err, pkg.ErrInvalidRequest)
+	}
+	p.Len(p.deadLetters(), 3)
+}
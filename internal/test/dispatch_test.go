@@ -0,0 +1,99 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// DispatchTestSuite runs the workers against bursts of orders.
+type DispatchTestSuite struct {
+	workerSuite
+}
+
+func (p *DispatchTestSuite) SetupSuite() {
+	p.setup(config.ServiceConfig{
+		WorkerCount:         4,
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         1,
+	}, instantProcess{})
+}
+
+func TestDispatch(t *testing.T) {
+	suite.Run(t, new(DispatchTestSuite))
+}
+
+// processed counts the orders the workers processed.
+func (p *DispatchTestSuite) processed() int64 {
+	var count int64
+	p.NoError(repository.DB().Table("orders").Where("status = ?", pkg.StatusOrderManagementProcessed).Count(&count).Error)
+	return count
+}
+
+func (p *DispatchTestSuite) TestBurstsAreDrained() {
+	const creators, perCreator, batchSize = 8, 25, 100
+
+	var wg sync.WaitGroup
+	for c := 0; c < creators; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := 0; i < perCreator; i++ {
+				_, err := p.orderService.CreateOrder(p.ctx, dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+					OrderID:        fmt.Sprintf("burst-%d-%d", c, i),
+					Priority:       pkg.PriorityOrderManagementNormal,
+					ProcessingTime: 1,
+				}})
+				p.NoError(err)
+			}
+		}(c)
+	}
+
+	batch := make([]dto.BaseCreateOrderRequest, batchSize)
+	for i := range batch {
+		batch[i] = dto.BaseCreateOrderRequest{
+			OrderID:        fmt.Sprintf("batch-%d", i),
+			Priority:       pkg.PriorityOrderManagementHigh,
+			ProcessingTime: 1,
+		}
+	}
+	_, err := p.orderService.CreateOrders(p.ctx, dto.CreateOrdersUsecaseRequest{Orders: batch})
+	p.NoError(err)
+	wg.Wait()
+
+	p.Eventually(func() bool {
+		return p.processed() == creators*perCreator+batchSize
+	}, 30*time.Second, 50*time.Millisecond)
+}
+
+func (p *DispatchTestSuite) TestOrdersQueuedElsewhereArePolled() {
+	// Let the workers drain the queue and idle
+	_, err := p.orderService.CreateOrder(p.ctx, dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+		OrderID:        "local",
+		Priority:       pkg.PriorityOrderManagementNormal,
+		ProcessingTime: 1,
+	}})
+	p.NoError(err)
+	p.Eventually(func() bool {
+		return p.processed() == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// Orders written straight to the repository wake no worker, as if another
+	// process had queued them
+	for i := 0; i < 10; i++ {
+		p.queueOrder(fmt.Sprintf("elsewhere-%d", i), pkg.PriorityOrderManagementNormal)
+	}
+
+	p.Eventually(func() bool {
+		return p.processed() == 11
+	}, 10*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	httpDelivery "github.com/seyedmo30/order_management/internal/delivery/http"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// ErrorTestSuite covers the error kinds and how they are rendered as problem
+// details.
+type ErrorTestSuite struct {
+	suite.Suite
+}
+
+func TestError(t *testing.T) {
+	suite.Run(t, new(ErrorTestSuite))
+}
+
+func (p *ErrorTestSuite) TestErrorKinds() {
+	p.True(errors.Is(pkg.ErrNotFound.WithDescription("order 42"), pkg.ErrNotFound))
+	p.True(errors.Is(fmt.Errorf("loading order: %w", pkg.ErrDuplicateEntry), pkg.ErrDuplicateEntry))
+	p.False(errors.Is(pkg.ErrStatusConflict, pkg.ErrLockNotHeld))
+	p.False(errors.Is(&pkg.ErrorCustom{Code: 404}, pkg.ErrNotFound))
+
+	transitionErr := error(&pkg.StateTransitionError{OrderID: "42", From: pkg.StatusOrderManagementProcessed, To: pkg.StatusOrderManagementCancelled})
+	p.True(errors.Is(transitionErr, pkg.ErrInvalidTransition))
+	var customErr *pkg.ErrorCustom
+	p.True(errors.As(transitionErr, &customErr))
+	p.Equal(http.StatusConflict, customErr.Code)
+}
+
+// render renders err through the error handler.
+func (p *ErrorTestSuite) render(err error) (*httptest.ResponseRecorder, map[string]interface{}) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil), rec)
+	httpDelivery.ErrorHandler(err, c)
+
+	var body map[string]interface{}
+	p.NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec, body
+}
+
+func (p *ErrorTestSuite) TestProblemDetails() {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+		detail interface{}
+	}{
+		{pkg.ErrNotFound, http.StatusNotFound, "not_found", nil},
+		{fmt.Errorf("creating order: %w", pkg.ErrDuplicateEntry), http.StatusConflict, "duplicate_entry", nil},
+		{pkg.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused", nil},
+		{pkg.ErrInvalidRequest.WithDescription("order_id is required"), http.StatusBadRequest, "invalid_request", "order_id is required"},
+		{&pkg.StateTransitionError{OrderID: "42", From: "Processed", To: "Cancelled"}, http.StatusConflict, "invalid_transition", "invalid status transition of order 42 from Processed to Cancelled"},
+		{echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed", nil},
+		{errors.New("disk full"), http.StatusInternalServerError, "internal", nil},
+	}
+
+	for _, tc := range cases {
+		rec, body := p.render(tc.err)
+		p.Equal(tc.status, rec.Code, tc.err)
+		p.Equal("application/problem+json", rec.Header().Get(echo.HeaderContentType))
+		p.Equal(float64(tc.status), body["status"])
+		p.Equal(tc.code, body["code"])
+		p.Equal("urn:order-management:problem:"+tc.code, body["type"])
+		p.Equal("/api/v1/orders/42", body["instance"])
+		p.Equal(tc.detail, body["detail"], tc.err)
+		p.NotEmpty(body["title"])
+	}
+}
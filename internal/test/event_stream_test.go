@@ -0,0 +1,188 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// EventStreamTestSuite streams order events. The streams poll the repository only
+// once a minute, so an event that arrives sooner was pushed by the event bus.
+// Every test starts without orders in a database file of the suite's own.
+type EventStreamTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+	server            *echo.Echo
+
+	suite.Suite
+}
+
+func (p *EventStreamTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount:             1,
+			EventStreamPollInterval: 60,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.server = newServer(p.orderService, usecase.NewWebhookUseCase(cfg, repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *EventStreamTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *EventStreamTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	suite.Run(t, new(EventStreamTestSuite))
+}
+
+func (p *EventStreamTestSuite) createOrder(orderID string) {
+	_, err := p.orderService.CreateOrder(p.ctx, dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+		OrderID:        orderID,
+		Priority:       pkg.PriorityOrderManagementNormal,
+		ProcessingTime: 1,
+	}})
+	p.NoError(err)
+}
+
+func (p *EventStreamTestSuite) cancelOrder(orderID string) {
+	p.NoError(p.orderService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: orderID, Actor: "test"}))
+}
+
+// eventIDs returns the IDs of the events recorded for the order.
+func (p *EventStreamTestSuite) eventIDs(orderID string) []uint {
+	res, err := p.repositoryService.ListOrderEvents(p.ctx, dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+	p.NoError(err)
+
+	ids := make([]uint, 0, len(res.Events))
+	for _, event := range res.Events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+// receive returns the next event of the stream, failing the test unless it
+// arrives within a second.
+func (p *EventStreamTestSuite) receive(events <-chan dto.BaseOrderEvent) dto.BaseOrderEvent {
+	select {
+	case event, ok := <-events:
+		p.Require().True(ok, "event stream closed")
+		return event
+	case <-time.After(time.Second):
+		p.FailNow("no event received")
+		return dto.BaseOrderEvent{}
+	}
+}
+
+func (p *EventStreamTestSuite) TestStreamReceivesStatusChange() {
+	p.createOrder("stream-01")
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	events, err := p.orderService.StreamOrderEvents(ctx, dto.StreamOrderEventsUsecaseRequest{OrderID: "stream-01"})
+	p.Require().NoError(err)
+
+	created := p.receive(events)
+	p.Equal(pkg.StatusOrderManagementPending, *created.ToStatus)
+
+	p.cancelOrder("stream-01")
+	cancelled := p.receive(events)
+	p.Equal("stream-01", *cancelled.OrderID)
+	p.Equal(pkg.StatusOrderManagementPending, *cancelled.FromStatus)
+	p.Equal(pkg.StatusOrderManagementCancelled, *cancelled.ToStatus)
+	p.Greater(cancelled.ID, created.ID)
+
+	// The stream ends with its context
+	cancel()
+	select {
+	case _, ok := <-events:
+		p.False(ok)
+	case <-time.After(time.Second):
+		p.Fail("event stream not closed")
+	}
+}
+
+func (p *EventStreamTestSuite) TestStreamResumesAfterLastEventID() {
+	for _, orderID := range []string{"stream-02", "stream-03", "stream-04"} {
+		p.createOrder(orderID)
+	}
+	lastEventID := p.eventIDs("stream-02")[0]
+
+	server := httptest.NewServer(p.server)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/orders/events", nil)
+	p.Require().NoError(err)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(uint64(lastEventID), 10))
+	res, err := http.DefaultClient.Do(req)
+	p.Require().NoError(err)
+	defer res.Body.Close()
+	p.Equal(http.StatusOK, res.StatusCode)
+	p.Equal("text/event-stream", res.Header.Get(echo.HeaderContentType))
+
+	ids := make(chan uint)
+	go func() {
+		defer close(ids)
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				n, err := strconv.ParseUint(id, 10, 64)
+				if err != nil {
+					return
+				}
+				ids <- uint(n)
+			}
+		}
+	}()
+	receive := func() uint {
+		select {
+		case id, ok := <-ids:
+			p.Require().True(ok, "event stream closed")
+			return id
+		case <-time.After(time.Second):
+			p.FailNow("no event received")
+			return 0
+		}
+	}
+
+	// The events recorded after the given one are sent first, then the new ones
+	p.Equal(p.eventIDs("stream-03")[0], receive())
+	p.Equal(p.eventIDs("stream-04")[0], receive())
+
+	p.cancelOrder("stream-02")
+	p.Equal(p.eventIDs("stream-02")[1], receive())
+}
+
+func (p *EventStreamTestSuite) TestStreamRejectsInvalidLastEventID() {
+	rec := serve(p.server, http.MethodGet, "/api/v1/orders/events", nil, "Last-Event-ID", "latest")
+	p.Equal(http.StatusBadRequest, rec.Code)
+}
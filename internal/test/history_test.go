@@ -0,0 +1,177 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// HistoryTestSuite reads the history of orders through the API. Every test
+// starts without orders in a database file of the suite's own.
+type HistoryTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	server            *echo.Echo
+
+	suite.Suite
+}
+
+func (p *HistoryTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.server = newServer(usecase.NewOrderUseCase(cfg, repositoryService, nil), usecase.NewWebhookUseCase(cfg, repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *HistoryTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *HistoryTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	suite.Run(t, new(HistoryTestSuite))
+}
+
+// historyResponse is the response to an order history request.
+type historyResponse struct {
+	OrderID string `json:"order_id"`
+	History []struct {
+		ID         uint    `json:"id"`
+		OrderID    string  `json:"order_id"`
+		Actor      string  `json:"actor"`
+		FromStatus *string `json:"from_status"`
+		ToStatus   string  `json:"to_status"`
+	} `json:"history"`
+}
+
+// createOrder creates an order through the API, on behalf of the tester.
+func (p *HistoryTestSuite) createOrder(orderID string) {
+	rec := serve(p.server, http.MethodPost, "/api/v1/orders",
+		strings.NewReader(`{"order_id": "`+orderID+`", "priority": "Normal", "processing_time": 1}`),
+		echo.HeaderContentType, echo.MIMEApplicationJSON,
+		"X-Actor", "tester",
+	)
+	p.Require().Equal(http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
+// processOrder claims the next order as owner and completes it as processed.
+func (p *HistoryTestSuite) processOrder(owner string) string {
+	acquiredAt := time.Now()
+	expiresAt := acquiredAt.Add(time.Minute)
+	claimed, err := p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			LockOwner:      &owner,
+			LockAcquiredAt: &acquiredAt,
+			LockExpiresAt:  &expiresAt,
+		},
+		Transition: dto.StatusTransition{
+			From:  claimTransition.From,
+			To:    claimTransition.To,
+			Actor: owner,
+		},
+	})
+	p.Require().NoError(err)
+
+	attempt := 1
+	status := pkg.StatusOrderManagementProcessed
+	finishedAt := time.Now()
+	err = p.repositoryService.CompleteOrder(p.ctx, dto.CompleteOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			OrderID:   claimed.OrderID,
+			LockOwner: &owner,
+		},
+		Transition: dto.StatusTransition{
+			From:  []string{pkg.StatusOrderManagementRunning},
+			To:    status,
+			Actor: owner,
+		},
+		Attempt: dto.BaseOrderAttempt{
+			OrderID:    claimed.OrderID,
+			Attempt:    &attempt,
+			LockOwner:  &owner,
+			Status:     &status,
+			StartedAt:  &acquiredAt,
+			FinishedAt: &finishedAt,
+		},
+	})
+	p.Require().NoError(err)
+	return *claimed.OrderID
+}
+
+func (p *HistoryTestSuite) TestHistoryOfProcessedOrder() {
+	p.createOrder("history-00")
+	p.Equal("history-00", p.processOrder("history:worker-0"))
+	// The history of other orders is left out
+	p.createOrder("history-01")
+
+	rec := serve(p.server, http.MethodGet, "/api/v1/orders/history-00/history", nil)
+	p.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var res historyResponse
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	p.Equal("history-00", res.OrderID)
+	p.Require().Len(res.History, 3)
+
+	// Changes are listed oldest first, each from the status the previous one left
+	want := []struct {
+		actor string
+		to    string
+	}{
+		{"api:tester@192.0.2.1", pkg.StatusOrderManagementPending},
+		{"history:worker-0", pkg.StatusOrderManagementRunning},
+		{"history:worker-0", pkg.StatusOrderManagementProcessed},
+	}
+	for i, event := range res.History {
+		p.Equal("history-00", event.OrderID, i)
+		p.Equal(want[i].actor, event.Actor, i)
+		p.Equal(want[i].to, event.ToStatus, i)
+		if i == 0 {
+			p.Nil(event.FromStatus)
+			continue
+		}
+		p.Greater(event.ID, res.History[i-1].ID, i)
+		p.Require().NotNil(event.FromStatus, i)
+		p.Equal(res.History[i-1].ToStatus, *event.FromStatus, i)
+	}
+}
+
+func (p *HistoryTestSuite) TestHistoryOfUnknownOrder() {
+	p.createOrder("history-10")
+
+	rec := serve(p.server, http.MethodGet, "/api/v1/orders/history-11/history", nil)
+	p.Equal(http.StatusNotFound, rec.Code)
+	p.Equal("application/problem+json", rec.Header().Get(echo.HeaderContentType))
+
+	var body struct {
+		Code     string `json:"code"`
+		Instance string `json:"instance"`
+	}
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	p.Equal("not_found", body.Code)
+	p.Equal("/api/v1/orders/history-11/history", body.Instance)
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// IdempotencyTestSuite creates orders with and without idempotency keys. Every
+// test starts without orders or keys in an in-memory database of its own.
+type IdempotencyTestSuite struct {
+	ctx          context.Context
+	orderService interfaces.OrderUseCase
+
+	suite.Suite
+}
+
+func (p *IdempotencyTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: config.DatabaseConfig{
+			LogLevel:     "ERROR",
+			DSN:          "file:idempotency?mode=memory&cache=shared",
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		},
+		ServiceConfig: config.ServiceConfig{
+			IdempotencyKeyTTL: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.ctx = context.Background()
+}
+
+func (p *IdempotencyTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox", "idempotency_keys"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
+
+// createOrder creates an order with the given idempotency key, offering body as
+// the response to store with it.
+func (p *IdempotencyTestSuite) createOrder(orderID, priority, idempotencyKey, body string) (dto.CreateOrderUsecaseResponse, error) {
+	return p.orderService.CreateOrder(p.ctx, dto.CreateOrderUsecaseRequest{
+		BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+			OrderID:        orderID,
+			Priority:       priority,
+			ProcessingTime: 1,
+		},
+		IdempotencyKey: idempotencyKey,
+		Response:       dto.IdempotentResponse{Code: http.StatusAccepted, Body: []byte(body)},
+	})
+}
+
+func (p *IdempotencyTestSuite) countOrders() int64 {
+	var count int64
+	p.NoError(repository.DB().Model(&dto.BaseOrder{}).Count(&count).Error)
+	return count
+}
+
+func (p *IdempotencyTestSuite) TestReplayRetry() {
+	res, err := p.createOrder("idempotent-01", pkg.PriorityOrderManagementHigh, "key-01", `{"attempt":1}`)
+	p.NoError(err)
+	p.False(res.Replayed)
+	p.Equal(http.StatusAccepted, res.Response.Code)
+
+	// The retry gets the stored response rather than the one it offers
+	res, err = p.createOrder("idempotent-01", pkg.PriorityOrderManagementHigh, "key-01", `{"attempt":2}`)
+	p.NoError(err)
+	p.True(res.Replayed)
+	p.Equal(http.StatusAccepted, res.Response.Code)
+	p.Equal(`{"attempt":1}`, string(res.Response.Body))
+
+	p.Equal(int64(1), p.countOrders())
+}
+
+func (p *IdempotencyTestSuite) TestRejectKeyReuseForDifferentRequest() {
+	_, err := p.createOrder("idempotent-02", pkg.PriorityOrderManagementHigh, "key-02", "{}")
+	p.NoError(err)
+
+	for _, orderID := range []string{"idempotent-02", "idempotent-03"} {
+		_, err = p.createOrder(orderID, pkg.PriorityOrderManagementLow, "key-02", "{}")
+		var customErr *pkg.ErrorCustom
+		p.True(errors.As(err, &customErr))
+		p.Equal(http.StatusUnprocessableEntity, customErr.Code)
+	}
+
+	p.Equal(int64(1), p.countOrders())
+}
+
+func (p *IdempotencyTestSuite) TestRejectDuplicateOrderID() {
+	_, err := p.createOrder("idempotent-04", pkg.PriorityOrderManagementNormal, "", "{}")
+	p.NoError(err)
+
+	// Neither a plain retry nor one with a new key creates the order again
+	for _, idempotencyKey := range []string{"", "key-04"} {
+		_, err = p.createOrder("idempotent-04", pkg.PriorityOrderManagementNormal, idempotencyKey, "{}")
+		var customErr *pkg.ErrorCustom
+		p.True(errors.As(err, &customErr))
+		p.Equal(http.StatusConflict, customErr.Code)
+	}
+
+	// The failed creation did not store its key
+	res, err := p.createOrder("idempotent-05", pkg.PriorityOrderManagementNormal, "key-04", "{}")
+	p.NoError(err)
+	p.False(res.Replayed)
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// LeaseTestSuite runs a single worker whose leases last a second, while expired
+// leases are reaped every second. Every test queues its orders before starting
+// the worker; once idle, the worker only polls for orders every 30 seconds.
+type LeaseTestSuite struct {
+	workerSuite
+	gate chan struct{}
+}
+
+func (p *LeaseTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "lease",
+		WorkerCount:         1,
+		WorkerPollInterval:  30,
+		LeaseDuration:       1,
+		LeaseReaperInterval: 1,
+		MaxAttempts:         3,
+	}, gatedProcess{gate: p.gate})
+}
+
+func (p *LeaseTestSuite) SetupTest() {
+	p.deleteOrders()
+}
+
+func TestLease(t *testing.T) {
+	suite.Run(t, new(LeaseTestSuite))
+}
+
+func (p *LeaseTestSuite) TestRenewedLeaseIsNotReaped() {
+	p.queueOrder("lease-01", pkg.PriorityOrderManagementNormal)
+	p.startWorkers()
+	p.waitStatus("lease-01", pkg.StatusOrderManagementRunning)
+	claimed := p.order("lease-01")
+
+	// The order keeps being processed for longer than its lease and several
+	// rounds of the reaper
+	time.Sleep(2500 * time.Millisecond)
+	order := p.order("lease-01")
+	p.Equal(pkg.StatusOrderManagementRunning, *order.Status)
+	p.Equal(*claimed.LockOwner, *order.LockOwner)
+	p.True(order.LockExpiresAt.After(*claimed.LockExpiresAt))
+
+	p.gate <- struct{}{}
+	p.waitStatus("lease-01", pkg.StatusOrderManagementProcessed)
+	p.Equal(1, *p.order("lease-01").Attempts)
+}
+
+func (p *LeaseTestSuite) TestExpiredLeaseIsReaped() {
+	// A worker of another instance claimed the order and stopped renewing its
+	// lease, which expires once the worker has started
+	orderID, owner := "lease-02", "crashed:worker-0"
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime, attempts := 1, 1
+	acquiredAt := time.Now()
+	expiresAt := acquiredAt.Add(1500 * time.Millisecond)
+	err := p.repositoryService.CreateOrder(context.Background(), dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &pkg.StatusOrderManagementRunning,
+		ProcessingTime: &processingTime,
+		Attempts:       &attempts,
+		LockOwner:      &owner,
+		LockAcquiredAt: &acquiredAt,
+		LockExpiresAt:  &expiresAt,
+	}})
+	p.NoError(err)
+	p.startWorkers()
+	time.Sleep(500 * time.Millisecond)
+	p.Equal(owner, *p.order(orderID).LockOwner)
+
+	// The reaper returns it to the queue and wakes the worker, which claims it again
+	p.Eventually(func() bool {
+		order := p.order(orderID)
+		return *order.Status == pkg.StatusOrderManagementRunning && strings.HasPrefix(*order.LockOwner, "lease:")
+	}, 3*time.Second, 10*time.Millisecond)
+	p.Equal(2, *p.order(orderID).Attempts)
+
+	p.gate <- struct{}{}
+	p.waitStatus(orderID, pkg.StatusOrderManagementProcessed)
+
+	res, err := p.repositoryService.ListOrderEvents(context.Background(), dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+	p.NoError(err)
+	p.Require().Len(res.Events, 4)
+	released, reclaimed, processed := res.Events[1], res.Events[2], res.Events[3]
+	p.Equal(pkg.StatusOrderManagementPending, *released.ToStatus)
+	p.Equal("lease expired", *released.Reason)
+	p.Equal(pkg.StatusOrderManagementRunning, *reclaimed.ToStatus)
+	p.Equal(pkg.StatusOrderManagementProcessed, *processed.ToStatus)
+}
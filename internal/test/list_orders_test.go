@@ -0,0 +1,269 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// ListOrdersTestSuite lists orders through the API. Every test starts without
+// orders in a database file of the suite's own.
+type ListOrdersTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	server            *echo.Echo
+
+	suite.Suite
+}
+
+func (p *ListOrdersTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.server = newServer(usecase.NewOrderUseCase(cfg, repositoryService, nil), usecase.NewWebhookUseCase(cfg, repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *ListOrdersTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *ListOrdersTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	suite.Run(t, new(ListOrdersTestSuite))
+}
+
+// storedOrder is an order stored straight in the repository; a LockOwner marks
+// it as locked.
+type storedOrder struct {
+	OrderID   string
+	Status    string
+	Priority  string
+	CreatedAt time.Time
+	LockOwner string
+}
+
+func (p *ListOrdersTestSuite) storeOrders(orders ...storedOrder) {
+	for _, order := range orders {
+		order := order
+		priority := pkg.PriorityLevels[order.Priority]
+		processingTime := 1
+		base := dto.BaseOrder{
+			ID:             order.OrderID,
+			OrderID:        &order.OrderID,
+			Priority:       &priority,
+			Status:         &order.Status,
+			ProcessingTime: &processingTime,
+			CreatedAt:      &order.CreatedAt,
+			UpdatedAt:      &order.CreatedAt,
+		}
+		if order.LockOwner != "" {
+			expiresAt := time.Now().Add(time.Minute)
+			base.LockOwner = &order.LockOwner
+			base.LockExpiresAt = &expiresAt
+		}
+		p.Require().NoError(p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: base}))
+	}
+}
+
+// listOrdersResponse is the response to an order listing.
+type listOrdersResponse struct {
+	Orders []struct {
+		OrderID  string `json:"order_id"`
+		Status   string `json:"status"`
+		Priority string `json:"priority"`
+		Locked   bool   `json:"locked"`
+	} `json:"orders"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// orderIDs returns the IDs of the listed orders, in the order listed.
+func (r listOrdersResponse) orderIDs() []string {
+	orderIDs := make([]string, 0, len(r.Orders))
+	for _, order := range r.Orders {
+		orderIDs = append(orderIDs, order.OrderID)
+	}
+	return orderIDs
+}
+
+// listOrders lists the orders selected by the query. It fails the test unless
+// the listing succeeds.
+func (p *ListOrdersTestSuite) listOrders(query url.Values) listOrdersResponse {
+	rec := serve(p.server, http.MethodGet, "/api/v1/orders?"+query.Encode(), nil)
+	p.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var res listOrdersResponse
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+// requireProblem asserts that the query is rejected with the given problem code.
+func (p *ListOrdersTestSuite) requireProblem(query url.Values, code string) {
+	rec := serve(p.server, http.MethodGet, "/api/v1/orders?"+query.Encode(), nil)
+	p.Equal(http.StatusBadRequest, rec.Code, query.Encode())
+	p.Equal("application/problem+json", rec.Header().Get(echo.HeaderContentType), query.Encode())
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	p.Equal(code, body.Code, query.Encode())
+}
+
+func (p *ListOrdersTestSuite) TestListOrdersByFilter() {
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	p.storeOrders(
+		storedOrder{"list-00", pkg.StatusOrderManagementPending, pkg.PriorityOrderManagementHigh, start, ""},
+		storedOrder{"list-01", pkg.StatusOrderManagementFailed, pkg.PriorityOrderManagementLow, start.Add(time.Minute), ""},
+		storedOrder{"list-02", pkg.StatusOrderManagementRunning, pkg.PriorityOrderManagementHigh, start.Add(2 * time.Minute), "test:worker-0"},
+		storedOrder{"list-03", pkg.StatusOrderManagementProcessed, pkg.PriorityOrderManagementBulk, start.Add(3 * time.Minute), ""},
+	)
+
+	cases := []struct {
+		query    url.Values
+		orderIDs []string
+	}{
+		{url.Values{}, []string{"list-00", "list-01", "list-02", "list-03"}},
+		{url.Values{"status": {"Pending,Failed"}}, []string{"list-00", "list-01"}},
+		{url.Values{"status": {"Pending", "Failed"}}, []string{"list-00", "list-01"}},
+		{url.Values{"status": {" Running , "}}, []string{"list-02"}},
+		{url.Values{"priority": {"High"}}, []string{"list-00", "list-02"}},
+		{url.Values{"priority": {"High"}, "status": {"Running"}}, []string{"list-02"}},
+		{url.Values{"created_after": {start.Add(time.Minute).Format(time.RFC3339)}}, []string{"list-01", "list-02", "list-03"}},
+		{url.Values{"created_before": {start.Add(time.Minute).Format(time.RFC3339)}}, []string{"list-00"}},
+		{url.Values{"locked": {"true"}}, []string{"list-02"}},
+		{url.Values{"locked": {"false"}, "order": {"desc"}}, []string{"list-03", "list-01", "list-00"}},
+		{url.Values{"sort": {"priority"}, "order": {"desc"}}, []string{"list-02", "list-00", "list-01", "list-03"}},
+		{url.Values{"status": {"Cancelled"}}, []string{}},
+	}
+	for _, tc := range cases {
+		res := p.listOrders(tc.query)
+		p.Equal(tc.orderIDs, res.orderIDs(), tc.query.Encode())
+		p.Empty(res.NextCursor, tc.query.Encode())
+	}
+
+	res := p.listOrders(url.Values{"status": {"Running"}})
+	p.Require().Len(res.Orders, 1)
+	p.Equal(pkg.StatusOrderManagementRunning, res.Orders[0].Status)
+	p.Equal(pkg.PriorityOrderManagementHigh, res.Orders[0].Priority)
+	p.True(res.Orders[0].Locked)
+
+	for _, query := range []url.Values{
+		{"status": {"Unknown"}},
+		{"status": {"Pending,pending"}},
+		{"priority": {"Urgent"}},
+		{"created_after": {"yesterday"}},
+		{"locked": {"maybe"}},
+		{"sort": {"order_id"}},
+		{"order": {"up"}},
+	} {
+		p.requireProblem(query, "invalid_request")
+	}
+}
+
+func (p *ListOrdersTestSuite) TestListOrdersLimit() {
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	orders := make([]storedOrder, 0, 51)
+	for i := 0; i < 51; i++ {
+		orders = append(orders, storedOrder{fmt.Sprintf("limit-%02d", i), pkg.StatusOrderManagementPending, pkg.PriorityOrderManagementNormal, start.Add(time.Duration(i) * time.Second), ""})
+	}
+	p.storeOrders(orders...)
+
+	// Without a limit a page holds 50 orders
+	res := p.listOrders(url.Values{})
+	p.Len(res.Orders, 50)
+	p.NotEmpty(res.NextCursor)
+
+	res = p.listOrders(url.Values{"limit": {"1"}})
+	p.Equal([]string{"limit-00"}, res.orderIDs())
+	p.NotEmpty(res.NextCursor)
+
+	res = p.listOrders(url.Values{"limit": {"500"}})
+	p.Len(res.Orders, 51)
+	p.Empty(res.NextCursor)
+
+	for _, limit := range []string{"-1", "501", "ten"} {
+		p.requireProblem(url.Values{"limit": {limit}}, "invalid_request")
+	}
+}
+
+func (p *ListOrdersTestSuite) TestListOrdersMalformedCursor() {
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	p.storeOrders(
+		storedOrder{"cursor-00", pkg.StatusOrderManagementPending, pkg.PriorityOrderManagementNormal, start, ""},
+		storedOrder{"cursor-01", pkg.StatusOrderManagementPending, pkg.PriorityOrderManagementNormal, start.Add(time.Second), ""},
+	)
+
+	res := p.listOrders(url.Values{"limit": {"1"}})
+	p.Require().NotEmpty(res.NextCursor)
+
+	for _, query := range []url.Values{
+		{"cursor": {"not a cursor"}},
+		{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("[1, 2]"))}},
+		{"cursor": {res.NextCursor[:len(res.NextCursor)-2]}},
+		// A cursor only continues the listing order it was issued for
+		{"cursor": {res.NextCursor}, "order": {"desc"}},
+		{"cursor": {res.NextCursor}, "sort": {"updated_at"}},
+	} {
+		p.requireProblem(query, "invalid_cursor")
+	}
+}
+
+func (p *ListOrdersTestSuite) TestListOrdersPagesWithEqualCreationTimes() {
+	createdAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	orders := make([]storedOrder, 0, 7)
+	for _, i := range []int{3, 0, 6, 1, 5, 2, 4} {
+		orders = append(orders, storedOrder{fmt.Sprintf("page-%02d", i), pkg.StatusOrderManagementPending, pkg.PriorityOrderManagementNormal, createdAt, ""})
+	}
+	p.storeOrders(orders...)
+
+	// Orders created at once are paged by ID, none repeated or skipped
+	for _, order := range []string{"asc", "desc"} {
+		var orderIDs []string
+		pages := 0
+		query := url.Values{"limit": {"3"}, "order": {order}}
+		for {
+			res := p.listOrders(query)
+			pages++
+			p.LessOrEqual(len(res.Orders), 3)
+			orderIDs = append(orderIDs, res.orderIDs()...)
+			if res.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", res.NextCursor)
+		}
+
+		want := []string{"page-00", "page-01", "page-02", "page-03", "page-04", "page-05", "page-06"}
+		if order == "desc" {
+			want = []string{"page-06", "page-05", "page-04", "page-03", "page-02", "page-01", "page-00"}
+		}
+		p.Equal(want, orderIDs, order)
+		p.Equal(3, pages, order)
+	}
+}
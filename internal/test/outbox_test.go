@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/sink"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// OutboxTestSuite relays the order events written to the outbox to in-memory
+// sinks. Every test starts with an empty in-memory database of its own.
+type OutboxTestSuite struct {
+	ctx               context.Context
+	cfg               config.App
+	repositoryService interface {
+		interfaces.OrderRepository
+		interfaces.OutboxRepository
+	}
+
+	suite.Suite
+}
+
+func (p *OutboxTestSuite) SetupSuite() {
+	p.cfg = config.App{
+		DatabaseConfig: config.DatabaseConfig{
+			LogLevel:     "ERROR",
+			DSN:          "file:outbox?mode=memory&cache=shared",
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		},
+		ServiceConfig: config.ServiceConfig{
+			OutboxPollInterval: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(p.cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.ctx = context.Background()
+}
+
+func (p *OutboxTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	suite.Run(t, new(OutboxTestSuite))
+}
+
+// flakySink fails the first publishes it gets and records the rest.
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	messages []dto.BaseOutboxMessage
+}
+
+func (s *flakySink) Name() string {
+	return "flaky"
+}
+
+func (s *flakySink) Publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func (s *flakySink) published() []dto.BaseOutboxMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]dto.BaseOutboxMessage(nil), s.messages...)
+}
+
+// relay runs an outbox relay to the given sinks until the returned function is called.
+func (p *OutboxTestSuite) relay(sinks ...interfaces.OutboxSink) (stop func()) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = usecase.NewOutboxRelay(p.cfg, p.repositoryService, sinks...).RelayOutbox(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (p *OutboxTestSuite) createOrder(orderID string) {
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &pkg.StatusOrderManagementPending,
+		ProcessingTime: &processingTime,
+	}})
+	p.NoError(err)
+}
+
+func (p *OutboxTestSuite) TestWriteWithOrderMutation() {
+	orderID := "outbox-01"
+	p.createOrder(orderID)
+	err := p.repositoryService.CancelOrder(p.ctx, dto.CancelOrderRepositoryRequest{
+		BaseOrder:  dto.BaseOrder{OrderID: &orderID},
+		Transition: dto.StatusTransition{From: []string{pkg.StatusOrderManagementPending}, To: pkg.StatusOrderManagementCancelled},
+	})
+	p.NoError(err)
+
+	memory := sink.NewMemorySink()
+	messages, unsubscribe := memory.Subscribe()
+	defer unsubscribe()
+
+	stop := p.relay(memory)
+	defer stop()
+
+	for _, status := range []string{pkg.StatusOrderManagementPending, pkg.StatusOrderManagementCancelled} {
+		select {
+		case message := <-messages:
+			p.Equal(pkg.TopicOrderStatusChanged, *message.Topic)
+			p.Equal(orderID, *message.AggregateID)
+
+			var event dto.BaseOrderEvent
+			p.NoError(json.Unmarshal([]byte(*message.Payload), &event))
+			p.Equal(status, *event.ToStatus)
+		case <-time.After(5 * time.Second):
+			p.FailNow("outbox message not relayed", status)
+		}
+	}
+}
+
+func (p *OutboxTestSuite) TestRetryUntilPublished() {
+	p.createOrder("outbox-02")
+
+	flaky := &flakySink{failures: 1}
+	stop := p.relay(flaky)
+	p.Eventually(func() bool { return len(flaky.published()) == 1 }, 5*time.Second, 50*time.Millisecond)
+	stop()
+
+	var message dto.BaseOutboxMessage
+	p.NoError(repository.DB().Where("aggregate_id = ?", "outbox-02").First(&message).Error)
+	p.NotNil(message.PublishedAt)
+	// The first attempt failed, so publishing took a retry, which cleared the error
+	p.Equal(2, *message.Attempts)
+	p.Nil(message.LastError)
+}
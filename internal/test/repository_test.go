@@ -2,15 +2,19 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/seyedmo30/order_management/internal/config"
 	"github.com/seyedmo30/order_management/internal/dto"
 	"github.com/seyedmo30/order_management/internal/interfaces"
 	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/pkg"
 	"github.com/stretchr/testify/suite"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -36,7 +40,8 @@ func (p *RepositoryTestSuit) SetupSuite() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	repositoryService := repository.NewOrderManagementRepository(cfg)
+	repositoryService, err := repository.NewOrderManagementRepository(cfg)
+	p.Require().NoError(err)
 	p.repositoryService = repositoryService
 	p.ctx = context.Background()
 
@@ -46,6 +51,12 @@ func TestRepository(t *testing.T) {
 	suite.Run(t, new(RepositoryTestSuit))
 }
 
+// claimTransition moves a claimed order from the queue to Running.
+var claimTransition = dto.StatusTransition{
+	From: []string{pkg.StatusOrderManagementPending, pkg.StatusOrderManagementScheduled},
+	To:   pkg.StatusOrderManagementRunning,
+}
+
 func (p *RepositoryTestSuit) Test0CreateInvoicePayment() {
 	sm, err := GetSampleData()
 	p.NoError(err)
@@ -56,6 +67,9 @@ func (p *RepositoryTestSuit) Test0CreateInvoicePayment() {
 
 	p.NoError(err)
 
+	// Creating the order again violates the unique order ID
+	err = p.repositoryService.CreateOrder(p.ctx, request)
+	p.True(errors.Is(err, pkg.ErrDuplicateEntry))
 }
 
 func (p *RepositoryTestSuit) Test1GetOrderByID() {
@@ -72,15 +86,61 @@ func (p *RepositoryTestSuit) Test1GetOrderByID() {
 
 }
 
-func (p *RepositoryTestSuit) Test2GetNextHighPriorityReadyOrderAndLock() {
-
-	res, err := p.repositoryService.GetNextHighPriorityReadyOrder(p.ctx)
-
+func (p *RepositoryTestSuit) Test2ClaimNextOrderAndRenewLock() {
+	// Only Critical orders are claimed, so the order of the sample data is left
+	// for the next tests
+	orderID := "lease-01"
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementCritical]
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &pkg.StatusOrderManagementPending,
+		ProcessingTime: &processingTime,
+	}})
 	p.NoError(err)
 
-	lockOrderOptimisticRepositoryRequest := dto.LockOrderOptimisticRepositoryRequest{BaseOrder: res.BaseOrder}
+	claim := func(owner string, expiresAt time.Time) (dto.ClaimNextOrderRepositoryResponse, error) {
+		acquiredAt := time.Now()
+		return p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+			BaseOrder: dto.BaseOrder{
+				LockOwner:      &owner,
+				LockAcquiredAt: &acquiredAt,
+				LockExpiresAt:  &expiresAt,
+			},
+			Transition:  claimTransition,
+			Now:         acquiredAt,
+			MinPriority: priority,
+		})
+	}
 
-	err = p.repositoryService.LockOrderOptimistic(p.ctx, lockOrderOptimisticRepositoryRequest)
+	owner := "test:worker-0"
+	expiresAt := time.Now().Add(time.Minute)
+	res, err := claim(owner, expiresAt)
+	p.Require().NoError(err)
+	p.Equal(orderID, *res.OrderID)
+	p.Equal(pkg.StatusOrderManagementRunning, *res.Status)
+	p.Equal(owner, *res.LockOwner)
+
+	// A second worker must not be able to take the lease while it is held
+	otherOwner := "test:worker-1"
+	_, err = claim(otherOwner, expiresAt)
+	p.ErrorIs(err, pkg.ErrNotFound)
+
+	renewedUntil := expiresAt.Add(time.Minute)
+	err = p.repositoryService.RenewOrderLock(p.ctx, dto.RenewOrderLockRepositoryRequest{BaseOrder: dto.BaseOrder{
+		OrderID:       res.OrderID,
+		LockOwner:     &otherOwner,
+		LockExpiresAt: &renewedUntil,
+	}})
+	p.Error(err)
+
+	err = p.repositoryService.RenewOrderLock(p.ctx, dto.RenewOrderLockRepositoryRequest{BaseOrder: dto.BaseOrder{
+		OrderID:       res.OrderID,
+		LockOwner:     &owner,
+		LockExpiresAt: &renewedUntil,
+	}})
 	p.NoError(err)
 
 }
@@ -95,6 +155,10 @@ func (p *RepositoryTestSuit) Test3UpdateOrderByID() {
 
 	p.NoError(err)
 
+	// The status only changes through the order lifecycle
+	res, err := p.repositoryService.GetOrderByID(p.ctx, *request.OrderID)
+	p.NoError(err)
+	p.Equal(pkg.StatusOrderManagementPending, *res.Status)
 }
 
 func (p *RepositoryTestSuit) Test4ListAggregateOrderReport() {
@@ -106,6 +170,428 @@ func (p *RepositoryTestSuit) Test4ListAggregateOrderReport() {
 	fmt.Printf("%+v \n", res)
 }
 
+func (p *RepositoryTestSuit) Test5ReleaseOrphanedLocks() {
+	// The instance ID holds a LIKE wildcard, which must not match other owners
+	const instanceID = "re_lease"
+	now := time.Now()
+	// Leases stored with another offset sort wrongly when compared as text
+	east := time.FixedZone("UTC+14", 14*60*60)
+	west := time.FixedZone("UTC-12", -12*60*60)
+	releaseTransition := dto.StatusTransition{
+		From: []string{pkg.StatusOrderManagementRunning},
+		To:   pkg.StatusOrderManagementPending,
+	}
+
+	// Release the expired leases left by earlier tests
+	_, err := p.repositoryService.ReleaseOrphanedLocks(p.ctx, dto.ReleaseOrphanedLocksRepositoryRequest{Now: now, Transition: releaseTransition})
+	p.NoError(err)
+
+	leases := []struct {
+		orderID   string
+		owner     string
+		expiresAt time.Time
+	}{
+		{"release-expired", "other:worker-0", now.Add(-time.Minute)},
+		{"release-owned", instanceID + ":worker-0", now.Add(time.Minute)},
+		{"release-expired-east", "other:worker-2", now.Add(-time.Minute).In(east)},
+		{"release-held", "other:worker-1", now.Add(time.Minute)},
+		{"release-held-west", "other:worker-3", now.Add(time.Minute).In(west)},
+		{"release-lookalike", "reXlease:worker-0", now.Add(time.Minute)},
+		{"release-other-case", "RE_LEASE:worker-0", now.Add(time.Minute)},
+	}
+	for _, lease := range leases {
+		lease := lease
+		priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+		processingTime := 1
+		acquiredAt := lease.expiresAt.Add(-2 * time.Minute)
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             lease.orderID,
+			OrderID:        &lease.orderID,
+			Priority:       &priority,
+			Status:         &pkg.StatusOrderManagementRunning,
+			ProcessingTime: &processingTime,
+			LockOwner:      &lease.owner,
+			LockAcquiredAt: &acquiredAt,
+			LockExpiresAt:  &lease.expiresAt,
+		}})
+		p.NoError(err)
+	}
+
+	released, err := p.repositoryService.ReleaseOrphanedLocks(p.ctx, dto.ReleaseOrphanedLocksRepositoryRequest{
+		InstanceID: instanceID,
+		Now:        now,
+		Transition: releaseTransition,
+	})
+	p.NoError(err)
+	p.Equal(int64(3), released)
+
+	for _, orderID := range []string{"release-expired", "release-owned", "release-expired-east"} {
+		order, err := p.repositoryService.GetOrderByID(p.ctx, orderID)
+		p.NoError(err)
+		p.Equal(pkg.StatusOrderManagementPending, *order.Status, orderID)
+		p.Nil(order.LockOwner, orderID)
+		p.Nil(order.LockExpiresAt, orderID)
+	}
+
+	// The live leases of other instances are kept
+	for _, lease := range leases[3:] {
+		order, err := p.repositoryService.GetOrderByID(p.ctx, lease.orderID)
+		p.NoError(err)
+		p.Equal(pkg.StatusOrderManagementRunning, *order.Status, lease.orderID)
+		p.Equal(lease.owner, *order.LockOwner, lease.orderID)
+	}
+}
+
+func (p *RepositoryTestSuit) Test6ClaimNextOrderConcurrently() {
+	const orderCount = 20
+	const workerCount = 5
+
+	for i := 0; i < orderCount; i++ {
+		orderID := fmt.Sprintf("claim-%02d", i)
+		priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+		status := pkg.StatusOrderManagementPending
+		processingTime := 1
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &status,
+			ProcessingTime: &processingTime,
+		}})
+		p.NoError(err)
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[string]string)
+	var wg sync.WaitGroup
+	for w := 0; w < workerCount; w++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			for {
+				acquiredAt := time.Now()
+				expiresAt := acquiredAt.Add(time.Minute)
+				res, err := p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+					BaseOrder: dto.BaseOrder{
+						LockOwner:      &owner,
+						LockAcquiredAt: &acquiredAt,
+						LockExpiresAt:  &expiresAt,
+					},
+					Transition: claimTransition,
+				})
+				var customErr *pkg.ErrorCustom
+				if errors.As(err, &customErr) && customErr.Code == 404 {
+					return
+				}
+				if err != nil {
+					// the shared in-memory database reports table locks instead of waiting
+					continue
+				}
+
+				mu.Lock()
+				previous, ok := claimed[*res.OrderID]
+				claimed[*res.OrderID] = owner
+				mu.Unlock()
+				p.False(ok, "order %s claimed by %s and %s", *res.OrderID, previous, owner)
+				p.Equal(owner, *res.LockOwner)
+			}
+		}(fmt.Sprintf("test:worker-%d", w))
+	}
+	wg.Wait()
+
+	// Orders left queued by other tests of the suite are claimed as well
+	for i := 0; i < orderCount; i++ {
+		p.Contains(claimed, fmt.Sprintf("claim-%02d", i))
+	}
+}
+
+func (p *RepositoryTestSuit) Test7ClaimNextOrderByPriorityLevel() {
+	// Create the orders from the lowest to the highest level, so that creation
+	// order alone would serve them the wrong way around.
+	names := []string{
+		pkg.PriorityOrderManagementBulk,
+		pkg.PriorityOrderManagementLow,
+		pkg.PriorityOrderManagementNormal,
+		pkg.PriorityOrderManagementHigh,
+		pkg.PriorityOrderManagementCritical,
+	}
+	for _, name := range names {
+		orderID := "priority-" + name
+		priority := pkg.PriorityLevels[name]
+		status := pkg.StatusOrderManagementPending
+		processingTime := 1
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &status,
+			ProcessingTime: &processingTime,
+		}})
+		p.NoError(err)
+	}
+
+	owner := "test:worker-0"
+	for i := len(names) - 1; i >= 0; i-- {
+		acquiredAt := time.Now()
+		expiresAt := acquiredAt.Add(time.Minute)
+		res, err := p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+			BaseOrder: dto.BaseOrder{
+				LockOwner:      &owner,
+				LockAcquiredAt: &acquiredAt,
+				LockExpiresAt:  &expiresAt,
+			},
+			Transition: claimTransition,
+		})
+		p.NoError(err)
+		p.Equal(pkg.PriorityLevels[names[i]], *res.Priority)
+	}
+}
+
+func (p *RepositoryTestSuit) Test8ClaimNextOrderWithPriorityAging() {
+	now := time.Now()
+	aging := pkg.PriorityAging{Interval: time.Minute, Step: 1}
+
+	// A Normal order that has waited 20 minutes outranks a High order created now
+	orders := []struct {
+		orderID   string
+		priority  string
+		createdAt time.Time
+	}{
+		{"aging-high", pkg.PriorityOrderManagementHigh, now},
+		{"aging-normal", pkg.PriorityOrderManagementNormal, now.Add(-20 * time.Minute)},
+	}
+	for _, o := range orders {
+		orderID := o.orderID
+		priority := pkg.PriorityLevels[o.priority]
+		status := pkg.StatusOrderManagementPending
+		processingTime := 1
+		createdAt := o.createdAt
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &status,
+			ProcessingTime: &processingTime,
+			CreatedAt:      &createdAt,
+		}})
+		p.NoError(err)
+	}
+	p.Greater(
+		aging.Effective(pkg.PriorityLevels[pkg.PriorityOrderManagementNormal], now.Add(-20*time.Minute), now),
+		aging.Effective(pkg.PriorityLevels[pkg.PriorityOrderManagementHigh], now, now),
+	)
+
+	owner := "test:worker-0"
+	for _, expected := range []string{"aging-normal", "aging-high"} {
+		expiresAt := now.Add(time.Minute)
+		res, err := p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+			BaseOrder: dto.BaseOrder{
+				LockOwner:      &owner,
+				LockAcquiredAt: &now,
+				LockExpiresAt:  &expiresAt,
+			},
+			Transition: claimTransition,
+			Aging:      aging,
+			Now:        now,
+		})
+		p.NoError(err)
+		p.Equal(expected, *res.OrderID)
+	}
+}
+
+func (p *RepositoryTestSuit) Test9CancelOrder() {
+	orderID := "cancel-01"
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	status := pkg.StatusOrderManagementPending
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &status,
+		ProcessingTime: &processingTime,
+	}})
+	p.NoError(err)
+
+	reason := "customer request"
+	request := dto.CancelOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{OrderID: &orderID, CancelReason: &reason},
+		Transition: dto.StatusTransition{
+			From: []string{pkg.StatusOrderManagementPending},
+			To:   pkg.StatusOrderManagementCancelled,
+		},
+	}
+	err = p.repositoryService.CancelOrder(p.ctx, request)
+	p.NoError(err)
+
+	res, err := p.repositoryService.GetOrderByID(p.ctx, orderID)
+	p.NoError(err)
+	p.Equal(pkg.StatusOrderManagementCancelled, *res.Status)
+	p.Equal(reason, *res.CancelReason)
+
+	// The order is no longer in the status the cancellation was validated against
+	var customErr *pkg.ErrorCustom
+	err = p.repositoryService.CancelOrder(p.ctx, request)
+	p.True(errors.As(err, &customErr))
+	p.Equal(409, customErr.Code)
+	p.True(errors.Is(err, pkg.ErrStatusConflict))
+
+	unknownOrderID := "cancel-unknown"
+	request.OrderID = &unknownOrderID
+	err = p.repositoryService.CancelOrder(p.ctx, request)
+	p.True(errors.As(err, &customErr))
+	p.Equal(404, customErr.Code)
+	p.True(errors.Is(err, pkg.ErrNotFound))
+}
+
+func (p *RepositoryTestSuit) Test10ListAndReplayDeadLetters() {
+	orderID := "dead-letter-01"
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	status := pkg.StatusOrderManagementDeadLettered
+	processingTime := 1
+	attempts := 3
+	deadLetteredAt := time.Now()
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &status,
+		ProcessingTime: &processingTime,
+		Attempts:       &attempts,
+		DeadLetteredAt: &deadLetteredAt,
+	}})
+	p.NoError(err)
+
+	res, err := p.repositoryService.ListDeadLetters(p.ctx, dto.ListDeadLettersRepositoryRequest{Limit: 10})
+	p.NoError(err)
+	p.Len(res.DeadLetters, 1)
+	p.Equal(orderID, *res.DeadLetters[0].OrderID)
+
+	request := dto.ReplayDeadLettersRepositoryRequest{
+		OrderIDs: []string{orderID},
+		Transition: dto.StatusTransition{
+			From: []string{pkg.StatusOrderManagementDeadLettered},
+			To:   pkg.StatusOrderManagementPending,
+		},
+	}
+	replayed, err := p.repositoryService.ReplayDeadLetters(p.ctx, request)
+	p.NoError(err)
+	p.Equal([]string{orderID}, replayed.OrderIDs)
+
+	order, err := p.repositoryService.GetOrderByID(p.ctx, orderID)
+	p.NoError(err)
+	p.Equal(pkg.StatusOrderManagementPending, *order.Status)
+	p.Equal(0, *order.Attempts)
+
+	// A replayed order is no longer a dead letter
+	replayed, err = p.repositoryService.ReplayDeadLetters(p.ctx, request)
+	p.NoError(err)
+	p.Empty(replayed.OrderIDs)
+}
+
+func (p *RepositoryTestSuit) Test11ListOrders() {
+	const orderCount = 5
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementBulk]
+	createdAt := time.Now().Add(-time.Hour)
+	for i := 0; i < orderCount; i++ {
+		orderID := fmt.Sprintf("list-%02d", i)
+		status := pkg.StatusOrderManagementPending
+		processingTime := 1
+		orderCreatedAt := createdAt.Add(time.Duration(i) * time.Second)
+		err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &status,
+			ProcessingTime: &processingTime,
+			CreatedAt:      &orderCreatedAt,
+		}})
+		p.NoError(err)
+	}
+
+	// Page through the orders newest first, two at a time
+	locked := false
+	request := dto.ListOrdersRepositoryRequest{
+		Priorities:   []int{priority},
+		Statuses:     []string{pkg.StatusOrderManagementPending},
+		CreatedAfter: &createdAt,
+		Locked:       &locked,
+		SortBy:       "created_at",
+		Descending:   true,
+		Limit:        2,
+	}
+	var listed []string
+	for {
+		res, err := p.repositoryService.ListOrders(p.ctx, request)
+		p.NoError(err)
+		for _, order := range res.Orders {
+			listed = append(listed, *order.OrderID)
+		}
+		if res.Next == nil {
+			break
+		}
+		request.After = res.Next
+	}
+	p.Equal([]string{"list-04", "list-03", "list-02", "list-01", "list-00"}, listed)
+
+	locked = true
+	res, err := p.repositoryService.ListOrders(p.ctx, request)
+	p.NoError(err)
+	p.Empty(res.Orders)
+}
+
+func (p *RepositoryTestSuit) Test12ListOrderEvents() {
+	orderID := "history-01"
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	status := pkg.StatusOrderManagementPending
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			ID:             orderID,
+			OrderID:        &orderID,
+			Priority:       &priority,
+			Status:         &status,
+			ProcessingTime: &processingTime,
+		},
+		Actor: "api:test",
+	})
+	p.NoError(err)
+
+	reason := "customer request"
+	err = p.repositoryService.CancelOrder(p.ctx, dto.CancelOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{OrderID: &orderID, CancelReason: &reason},
+		Transition: dto.StatusTransition{
+			From:   []string{pkg.StatusOrderManagementPending},
+			To:     pkg.StatusOrderManagementCancelled,
+			Actor:  "api:test",
+			Reason: reason,
+		},
+	})
+	p.NoError(err)
+
+	res, err := p.repositoryService.ListOrderEvents(p.ctx, dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+	p.NoError(err)
+	p.Len(res.Events, 2)
+
+	created, cancelled := res.Events[0], res.Events[1]
+	p.Nil(created.FromStatus)
+	p.Equal(pkg.StatusOrderManagementPending, *created.ToStatus)
+	p.Equal("api:test", *created.Actor)
+	p.Equal(pkg.StatusOrderManagementPending, *cancelled.FromStatus)
+	p.Equal(pkg.StatusOrderManagementCancelled, *cancelled.ToStatus)
+	p.Equal(reason, *cancelled.Reason)
+
+	// Events after a given ID resume a stream where it left off
+	latest, err := p.repositoryService.LatestOrderEventID(p.ctx)
+	p.NoError(err)
+	p.Equal(cancelled.ID, latest)
+
+	res, err = p.repositoryService.ListOrderEvents(p.ctx, dto.ListOrderEventsRepositoryRequest{AfterID: created.ID, Limit: 10})
+	p.NoError(err)
+	p.Len(res.Events, 1)
+	p.Equal(cancelled.ID, res.Events[0].ID)
+}
+
 func (p *RepositoryTestSuit) TestFinalGetOrderByID() {
 	sm, err := GetSampleData()
 	p.NoError(err)
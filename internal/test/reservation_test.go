@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// ReservationTestSuite runs a shared worker and a worker reserved for High
+// orders.
+type ReservationTestSuite struct {
+	workerSuite
+	gate chan struct{}
+}
+
+func (p *ReservationTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "reservation",
+		WorkerCount:         1,
+		WorkerReservations:  map[string]int{pkg.PriorityOrderManagementHigh: 1},
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+	}, gatedProcess{gate: p.gate})
+}
+
+func TestReservation(t *testing.T) {
+	suite.Run(t, new(ReservationTestSuite))
+}
+
+func (p *ReservationTestSuite) TestReservedWorkerKeptForUrgentOrders() {
+	var res dto.WorkerPoolUsecaseResponse
+	p.Eventually(func() bool {
+		var err error
+		res, err = p.orderService.GetWorkerPool(context.Background())
+		return err == nil && len(res.Workers) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	p.Equal(1, res.Size)
+	reservedFor := make([]string, 0, len(res.Workers))
+	for _, worker := range res.Workers {
+		reservedFor = append(reservedFor, worker.ReservedFor)
+	}
+	p.ElementsMatch([]string{"", pkg.PriorityOrderManagementHigh}, reservedFor)
+
+	// Normal orders only take the shared worker
+	p.createOrder("normal-01", pkg.PriorityOrderManagementNormal)
+	p.waitStatus("normal-01", pkg.StatusOrderManagementRunning)
+	p.createOrder("normal-02", pkg.PriorityOrderManagementNormal)
+
+	// while the reserved worker takes an urgent order at once
+	p.createOrder("critical-01", pkg.PriorityOrderManagementCritical)
+	p.waitStatus("critical-01", pkg.StatusOrderManagementRunning)
+	p.Equal(pkg.StatusOrderManagementPending, p.status("normal-02"))
+
+	p.gate <- struct{}{}
+	p.gate <- struct{}{}
+	p.waitStatus("normal-02", pkg.StatusOrderManagementRunning)
+	p.gate <- struct{}{}
+	p.waitStatus("normal-02", pkg.StatusOrderManagementProcessed)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("normal-01"))
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("critical-01"))
+}
+
+func (p *ReservationTestSuite) TestUrgentOrdersBorrowSharedWorkers() {
+	p.createOrder("high-01", pkg.PriorityOrderManagementHigh)
+	p.createOrder("high-02", pkg.PriorityOrderManagementHigh)
+
+	// Both workers take a High order
+	p.waitStatus("high-01", pkg.StatusOrderManagementRunning)
+	p.waitStatus("high-02", pkg.StatusOrderManagementRunning)
+
+	p.gate <- struct{}{}
+	p.gate <- struct{}{}
+	p.waitStatus("high-01", pkg.StatusOrderManagementProcessed)
+	p.waitStatus("high-02", pkg.StatusOrderManagementProcessed)
+}
+
+// ReservationLevelsTestSuite runs no shared workers, but a worker reserved for
+// Critical orders and one reserved for High orders. The workers only poll for
+// orders every 30 seconds, so an order processed sooner woke its worker.
+type ReservationLevelsTestSuite struct {
+	workerSuite
+}
+
+func (p *ReservationLevelsTestSuite) SetupSuite() {
+	p.setup(config.ServiceConfig{
+		InstanceID: "reservation-levels",
+		WorkerReservations: map[string]int{
+			pkg.PriorityOrderManagementCritical: 1,
+			pkg.PriorityOrderManagementHigh:     1,
+		},
+		WorkerPollInterval:  30,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+	}, instantProcess{})
+}
+
+func TestReservationLevels(t *testing.T) {
+	suite.Run(t, new(ReservationLevelsTestSuite))
+}
+
+func (p *ReservationLevelsTestSuite) TestOrderWakesItsReservation() {
+	// The worker reserved for Critical orders cannot take the wake-up of a High
+	// order from the worker reserved for High ones
+	for _, orderID := range []string{"high-01", "high-02", "high-03"} {
+		p.createOrder(orderID, pkg.PriorityOrderManagementHigh)
+		p.waitStatus(orderID, pkg.StatusOrderManagementProcessed)
+	}
+
+	p.createOrder("critical-01", pkg.PriorityOrderManagementCritical)
+	p.waitStatus("critical-01", pkg.StatusOrderManagementProcessed)
+}
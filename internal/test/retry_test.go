@@ -0,0 +1,83 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// failingProcess fails every order it processes.
+type failingProcess struct{}
+
+func (failingProcess) ProcessOrder(ctx context.Context, processingTime int) (string, error) {
+	return pkg.StatusOrderManagementFailed, nil
+}
+
+// RetryTestSuite runs workers that fail every attempt. An order is attempted four
+// times, waiting a second after the first failure, doubled after each further
+// one up to two seconds.
+type RetryTestSuite struct {
+	workerSuite
+}
+
+func (p *RetryTestSuite) SetupSuite() {
+	p.setup(config.ServiceConfig{
+		InstanceID:          "retry",
+		WorkerCount:         1,
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         4,
+		RetryBackoffBase:    1,
+		RetryBackoffMax:     2,
+		DeadLetterEnabled:   true,
+	}, failingProcess{})
+}
+
+func TestRetry(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
+
+func (p *RetryTestSuite) TestFailedAttemptIsScheduled() {
+	p.createOrder("retry-01", pkg.PriorityOrderManagementNormal)
+	p.waitStatus("retry-01", pkg.StatusOrderManagementScheduled)
+
+	order := p.order("retry-01")
+	p.Equal(1, *order.Attempts)
+	p.Equal("processing failed on attempt 1", *order.LastError)
+	p.Require().NotNil(order.NextAttemptAt)
+	p.WithinDuration(time.Now().Add(time.Second), *order.NextAttemptAt, 500*time.Millisecond)
+	p.Nil(order.LockOwner)
+}
+
+func (p *RetryTestSuite) TestBackoffDoublesUntilAttemptsAreSpent() {
+	p.createOrder("retry-02", pkg.PriorityOrderManagementNormal)
+	p.Eventually(func() bool {
+		return p.status("retry-02") == pkg.StatusOrderManagementDeadLettered
+	}, 15*time.Second, 10*time.Millisecond)
+
+	order := p.order("retry-02")
+	p.Equal(4, *order.Attempts)
+	p.Equal("processing failed on attempt 4", *order.LastError)
+	p.NotNil(order.DeadLetteredAt)
+
+	res, err := p.repositoryService.ListOrderEvents(context.Background(), dto.ListOrderEventsRepositoryRequest{OrderID: "retry-02"})
+	p.NoError(err)
+	var failures []string
+	for _, event := range res.Events {
+		if *event.ToStatus == pkg.StatusOrderManagementScheduled || *event.ToStatus == pkg.StatusOrderManagementDeadLettered {
+			failures = append(failures, *event.Reason)
+		}
+	}
+	p.Equal([]string{
+		"processing failed on attempt 1; retrying in 1s",
+		"processing failed on attempt 2; retrying in 2s",
+		"processing failed on attempt 3; retrying in 2s",
+		"processing failed on attempt 4",
+	}, failures)
+}
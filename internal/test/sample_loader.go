@@ -21,10 +21,9 @@ type SampleData struct {
 }
 
 type RepositoryData struct {
-	CreatOrderRepositoryRequest          dto.CreatOrderRepositoryRequest
-	GetOrderByIDRepositoryResponse       string
-	UpdateOrderByIDRepositoryRequest     dto.UpdateOrderByIDRepositoryRequest
-	LockOrderOptimisticRepositoryRequest dto.LockOrderOptimisticRepositoryRequest
+	CreatOrderRepositoryRequest      dto.CreatOrderRepositoryRequest
+	GetOrderByIDRepositoryResponse   string
+	UpdateOrderByIDRepositoryRequest dto.UpdateOrderByIDRepositoryRequest
 }
 
 var sampleData SampleData
@@ -41,12 +40,12 @@ func loadSampleData() error {
 	}
 	jsonFile, err := os.ReadFile(filename)
 	if err != nil {
-		logger.Error("failed to load sample data", err)
+		logger.Error("failed to load sample data", "error", err)
 		return err
 	}
 	err = json.Unmarshal(jsonFile, &sampleData)
 	if err != nil {
-		logger.Error("failed to unmarshal sample data", err)
+		logger.Error("failed to unmarshal sample data", "error", err)
 		return err
 	}
 	return nil
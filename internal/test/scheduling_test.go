@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// SchedulingTestSuite covers which order a worker claims next. Every test starts
+// from an empty orders table of its own in-memory database.
+type SchedulingTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+
+	suite.Suite
+}
+
+func (p *SchedulingTestSuite) SetupSuite() {
+	cfg := config.DatabaseConfig{
+		LogLevel:     "ERROR",
+		DSN:          "file:scheduling?mode=memory&cache=shared",
+		MaxOpenConns: 10,
+		MaxIdleConns: 5,
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.ctx = context.Background()
+}
+
+func (p *SchedulingTestSuite) SetupTest() {
+	p.NoError(repository.DB().Exec("DELETE FROM orders").Error)
+}
+
+func TestScheduling(t *testing.T) {
+	suite.Run(t, new(SchedulingTestSuite))
+}
+
+// createOrder stores a pending order; mutate may adjust it before it is stored.
+func (p *SchedulingTestSuite) createOrder(orderID string, priorityName string, mutate ...func(*dto.BaseOrder)) {
+	priority := pkg.PriorityLevels[priorityName]
+	status := pkg.StatusOrderManagementPending
+	processingTime := 1
+	order := dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &status,
+		ProcessingTime: &processingTime,
+	}
+	for _, m := range mutate {
+		m(&order)
+	}
+
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: order})
+	p.NoError(err)
+}
+
+// claim claims the next order for owner as ranked at now.
+func (p *SchedulingTestSuite) claim(owner string, now time.Time, aging pkg.PriorityAging) (dto.ClaimNextOrderRepositoryResponse, error) {
+	return p.claimAbove(owner, now, aging, 0)
+}
+
+// claimAbove claims the next order of at least the given priority level.
+func (p *SchedulingTestSuite) claimAbove(owner string, now time.Time, aging pkg.PriorityAging, minPriority int) (dto.ClaimNextOrderRepositoryResponse, error) {
+	expiresAt := now.Add(time.Minute)
+	return p.repositoryService.ClaimNextOrder(p.ctx, dto.ClaimNextOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			LockOwner:      &owner,
+			LockAcquiredAt: &now,
+			LockExpiresAt:  &expiresAt,
+		},
+		Transition: dto.StatusTransition{
+			From: []string{pkg.StatusOrderManagementPending, pkg.StatusOrderManagementScheduled},
+			To:   pkg.StatusOrderManagementRunning,
+		},
+		Aging:       aging,
+		Now:         now,
+		MinPriority: minPriority,
+	})
+}
+
+// isNotFound reports whether err is the repository's "nothing found" error.
+func isNotFound(err error) bool {
+	var customErr *pkg.ErrorCustom
+	return errors.As(err, &customErr) && customErr.Code == 404
+}
+
+func (p *SchedulingTestSuite) TestClaimNextOrderHonoursNextAttemptAt() {
+	now := time.Now()
+	nextAttemptAt := now.Add(time.Minute)
+	p.createOrder("retry-01", pkg.PriorityOrderManagementNormal, func(o *dto.BaseOrder) {
+		o.Status = &pkg.StatusOrderManagementScheduled
+		o.NextAttemptAt = &nextAttemptAt
+	})
+
+	// The retry is not due yet
+	_, err := p.claim("test:worker-0", now, pkg.PriorityAging{})
+	p.True(isNotFound(err))
+
+	res, err := p.claim("test:worker-0", nextAttemptAt, pkg.PriorityAging{})
+	p.NoError(err)
+	p.Equal("retry-01", *res.OrderID)
+	p.Equal(pkg.StatusOrderManagementRunning, *res.Status)
+	p.Equal(1, *res.Attempts)
+}
+
+func (p *SchedulingTestSuite) TestClaimNextOrderHonoursMinPriority() {
+	now := time.Now()
+	p.createOrder("normal-01", pkg.PriorityOrderManagementNormal, func(o *dto.BaseOrder) {
+		createdAt := now.Add(-time.Hour)
+		o.CreatedAt = &createdAt
+	})
+	p.createOrder("critical-01", pkg.PriorityOrderManagementCritical)
+
+	// Aging ranks the old Normal order first, but does not let it reach a High reservation
+	aging := pkg.PriorityAging{Interval: time.Second, Step: 1}
+	high := pkg.PriorityLevels[pkg.PriorityOrderManagementHigh]
+	res, err := p.claimAbove("test:worker-0", now, aging, high)
+	p.NoError(err)
+	p.Equal("critical-01", *res.OrderID)
+
+	_, err = p.claimAbove("test:worker-0", now, aging, high)
+	p.True(isNotFound(err))
+
+	res, err = p.claim("test:worker-1", now, aging)
+	p.NoError(err)
+	p.Equal("normal-01", *res.OrderID)
+}
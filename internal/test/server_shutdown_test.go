@@ -0,0 +1,191 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// heldOrderUseCase holds every order creation until release is closed, once it
+// has signalled entered.
+type heldOrderUseCase struct {
+	interfaces.OrderUseCase
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (u heldOrderUseCase) CreateOrder(ctx context.Context, req dto.CreateOrderUsecaseRequest) (dto.CreateOrderUsecaseResponse, error) {
+	u.entered <- struct{}{}
+	<-u.release
+	return u.OrderUseCase.CreateOrder(ctx, req)
+}
+
+// ServerShutdownTestSuite shuts the HTTP server down while requests are in
+// flight. Every test serves on a port of its own and starts without orders in a
+// database file of the suite's own.
+type ServerShutdownTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+	held              heldOrderUseCase
+	server            *echo.Echo
+	url               string
+
+	suite.Suite
+}
+
+func (p *ServerShutdownTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.ctx = context.Background()
+}
+
+func (p *ServerShutdownTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *ServerShutdownTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+
+	p.held = heldOrderUseCase{OrderUseCase: p.orderService, entered: make(chan struct{}, 1), release: make(chan struct{})}
+	p.server = newServer(p.held, nil)
+	p.server.HideBanner = true
+	p.server.HidePort = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	p.Require().NoError(err)
+	p.server.Listener = listener
+	p.url = "http://" + listener.Addr().String()
+	go p.server.Start("")
+}
+
+func (p *ServerShutdownTestSuite) TearDownTest() {
+	p.server.Close()
+}
+
+func TestServerShutdown(t *testing.T) {
+	suite.Run(t, new(ServerShutdownTestSuite))
+}
+
+// shutdown shuts the server down in the background, giving the requests in
+// flight five seconds. The result is sent to the returned channel.
+func (p *ServerShutdownTestSuite) shutdown() <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- p.server.Shutdown(ctx)
+	}()
+	return done
+}
+
+// send sends a request in the background. The response is sent to the returned
+// channel, and the error of the request fails the test.
+func (p *ServerShutdownTestSuite) send(method, path, body string) <-chan *http.Response {
+	res := make(chan *http.Response, 1)
+	go func() {
+		req, err := http.NewRequest(method, p.url+path, strings.NewReader(body))
+		if !p.NoError(err) {
+			close(res)
+			return
+		}
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		resp, err := http.DefaultClient.Do(req)
+		if !p.NoError(err) {
+			close(res)
+			return
+		}
+		res <- resp
+	}()
+	return res
+}
+
+// receive returns the response of a request, failing the test unless it arrives
+// within a second.
+func (p *ServerShutdownTestSuite) receive(res <-chan *http.Response) *http.Response {
+	select {
+	case resp, ok := <-res:
+		p.Require().True(ok, "request failed")
+		p.T().Cleanup(func() { resp.Body.Close() })
+		return resp
+	case <-time.After(time.Second):
+		p.FailNow("no response received")
+		return nil
+	}
+}
+
+func (p *ServerShutdownTestSuite) TestRequestInFlightFinishes() {
+	res := p.send(http.MethodPost, "/api/v1/orders", `{"order_id": "shutdown-01", "priority": "High", "processing_time": 1}`)
+	<-p.held.entered
+
+	done := p.shutdown()
+	select {
+	case err := <-done:
+		p.FailNow("server shut down with a request in flight", "error: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(p.held.release)
+	p.Equal(http.StatusAccepted, p.receive(res).StatusCode)
+	p.NoError(<-done)
+
+	order, err := p.repositoryService.GetOrderByID(p.ctx, "shutdown-01")
+	p.Require().NoError(err)
+	p.Equal(pkg.StatusOrderManagementPending, *order.Status)
+}
+
+func (p *ServerShutdownTestSuite) TestWaitCutShort() {
+	close(p.held.release)
+	p.Equal(http.StatusAccepted, p.receive(p.send(http.MethodPost, "/api/v1/orders", `{"order_id": "shutdown-02", "priority": "High", "processing_time": 1}`)).StatusCode)
+
+	res := p.send(http.MethodGet, "/api/v1/orders/shutdown-02?wait=30s", "")
+	time.Sleep(100 * time.Millisecond)
+
+	done := p.shutdown()
+	resp := p.receive(res)
+	p.Equal(http.StatusAccepted, resp.StatusCode)
+	var body struct {
+		OrderID string `json:"order_id"`
+		Status  string `json:"status"`
+	}
+	p.Require().NoError(json.NewDecoder(resp.Body).Decode(&body))
+	p.Equal("shutdown-02", body.OrderID)
+	p.Equal(pkg.StatusOrderManagementPending, body.Status)
+	p.NoError(<-done)
+}
+
+func (p *ServerShutdownTestSuite) TestEventStreamEnds() {
+	resp := p.receive(p.send(http.MethodGet, "/api/v1/orders/events", ""))
+	p.Equal(http.StatusOK, resp.StatusCode)
+
+	done := p.shutdown()
+	select {
+	case err := <-done:
+		p.NoError(err)
+	case <-time.After(time.Second):
+		p.Fail("event stream held up the shutdown")
+	}
+}
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"io"
+	"net/http/httptest"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	httpDelivery "github.com/seyedmo30/order_management/internal/delivery/http"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+)
+
+// requestValidator validates the requests as the service does.
+type requestValidator struct {
+	validator *validator.Validate
+}
+
+func (v *requestValidator) Validate(i interface{}) error {
+	return v.validator.Struct(i)
+}
+
+// newServer routes requests to the handlers of the given use cases, set up as
+// the service sets them up.
+func newServer(orderService interfaces.OrderUseCase, webhookService interfaces.WebhookUseCase) *echo.Echo {
+	e := echo.New()
+	e.Validator = &requestValidator{validator: validator.New()}
+	e.HTTPErrorHandler = httpDelivery.ErrorHandler
+	orderHandler := httpDelivery.NewOrderHandler(orderService)
+	e.Server.RegisterOnShutdown(orderHandler.Shutdown)
+	httpDelivery.RegisterRoutes(e, orderHandler, httpDelivery.NewWebhookHandler(webhookService))
+	return e
+}
+
+// serve answers a request with the server.
+func serve(e *echo.Echo, method, target string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
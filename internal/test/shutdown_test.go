@@ -0,0 +1,75 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// ShutdownTestSuite stops the workers while they process an order.
+type ShutdownTestSuite struct {
+	workerSuite
+	gate chan struct{}
+}
+
+func (p *ShutdownTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "shutdown",
+		WorkerCount:         2,
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+		ShutdownTimeout:     1,
+	}, gatedProcess{gate: p.gate})
+}
+
+func TestShutdown(t *testing.T) {
+	suite.Run(t, new(ShutdownTestSuite))
+}
+
+// startOrder creates an order and waits until a worker processes it.
+func (p *ShutdownTestSuite) startOrder(orderID string) {
+	p.createOrder(orderID, pkg.PriorityOrderManagementNormal)
+	p.waitStatus(orderID, pkg.StatusOrderManagementRunning)
+}
+
+func (p *ShutdownTestSuite) TestDrainOrdersInFlight() {
+	p.startOrder("drained")
+
+	p.cancel()
+	select {
+	case <-p.done:
+		p.Fail("workers stopped before the order in flight finished")
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// An order queued during shutdown is not claimed
+	p.createOrder("late", pkg.PriorityOrderManagementHigh)
+
+	p.gate <- struct{}{}
+	p.NoError(<-p.done)
+	p.done <- nil
+
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("drained"))
+	p.Equal(pkg.StatusOrderManagementPending, p.status("late"))
+}
+
+func (p *ShutdownTestSuite) TestReleaseUnfinishedOrders() {
+	p.startOrder("unfinished")
+
+	started := time.Now()
+	p.cancel()
+	p.NoError(<-p.done)
+	p.done <- nil
+	p.GreaterOrEqual(time.Since(started), time.Second)
+
+	order := p.order("unfinished")
+	p.Equal(pkg.StatusOrderManagementPending, *order.Status)
+	p.Nil(order.LockOwner)
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// racingRepository runs beforeCancel as an order is about to be cancelled, as if
+// another process changed it meanwhile.
+type racingRepository struct {
+	interfaces.OrderRepository
+	beforeCancel func(orderID string)
+}
+
+func (r *racingRepository) CancelOrder(ctx context.Context, params dto.CancelOrderRepositoryRequest) error {
+	if r.beforeCancel != nil {
+		r.beforeCancel(*params.OrderID)
+	}
+	return r.OrderRepository.CancelOrder(ctx, params)
+}
+
+// TransitionTestSuite requests status changes that the order lifecycle does not
+// allow, and ones racing with other status changes. Every test starts without
+// orders in a database file of the suite's own.
+type TransitionTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+	racing            *racingRepository
+	racingService     interfaces.OrderUseCase
+	server            *echo.Echo
+
+	suite.Suite
+}
+
+func (p *TransitionTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WorkerCount: 1,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(cfg, repositoryService, nil)
+	p.racing = &racingRepository{OrderRepository: repositoryService}
+	p.racingService = usecase.NewOrderUseCase(cfg, p.racing, nil)
+	p.server = newServer(p.orderService, usecase.NewWebhookUseCase(cfg, repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *TransitionTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *TransitionTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+	p.racing.beforeCancel = nil
+}
+
+func TestTransition(t *testing.T) {
+	suite.Run(t, new(TransitionTestSuite))
+}
+
+// storeOrder stores an order with the given status straight in the repository.
+func (p *TransitionTestSuite) storeOrder(orderID string, status string) {
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &status,
+		ProcessingTime: &processingTime,
+	}})
+	p.NoError(err)
+}
+
+func (p *TransitionTestSuite) status(orderID string) string {
+	order, err := p.repositoryService.GetOrderByID(p.ctx, orderID)
+	p.NoError(err)
+	return *order.Status
+}
+
+// requireTransitionError asserts that err rejects the status change of the order
+// from one status to another.
+func (p *TransitionTestSuite) requireTransitionError(err error, orderID string, from string, to string) {
+	var transitionErr *pkg.StateTransitionError
+	p.Require().ErrorAs(err, &transitionErr)
+	p.ErrorIs(err, pkg.ErrInvalidTransition)
+	p.Equal(pkg.StateTransitionError{OrderID: orderID, From: from, To: to}, *transitionErr)
+}
+
+func (p *TransitionTestSuite) TestCancelProcessedOrder() {
+	p.storeOrder("transition-01", pkg.StatusOrderManagementProcessed)
+
+	err := p.orderService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: "transition-01", Actor: "test"})
+	p.requireTransitionError(err, "transition-01", pkg.StatusOrderManagementProcessed, pkg.StatusOrderManagementCancelled)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("transition-01"))
+}
+
+func (p *TransitionTestSuite) TestCancelCancelledOrder() {
+	p.storeOrder("transition-02", pkg.StatusOrderManagementCancelled)
+
+	err := p.orderService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: "transition-02", Actor: "test"})
+	p.requireTransitionError(err, "transition-02", pkg.StatusOrderManagementCancelled, pkg.StatusOrderManagementCancelled)
+}
+
+func (p *TransitionTestSuite) TestReplayPendingOrder() {
+	p.storeOrder("transition-03", pkg.StatusOrderManagementPending)
+
+	err := p.orderService.ReplayDeadLetter(p.ctx, dto.ReplayDeadLetterUsecaseRequest{OrderID: "transition-03", Actor: "test"})
+	p.requireTransitionError(err, "transition-03", pkg.StatusOrderManagementPending, pkg.StatusOrderManagementPending)
+	p.Equal(pkg.StatusOrderManagementPending, p.status("transition-03"))
+}
+
+func (p *TransitionTestSuite) TestCancelProcessedOrderConflicts() {
+	p.storeOrder("transition-04", pkg.StatusOrderManagementProcessed)
+
+	rec := serve(p.server, http.MethodPost, "/api/v1/orders/transition-04/cancel", nil)
+	p.Equal(http.StatusConflict, rec.Code)
+	p.Equal("application/problem+json", rec.Header().Get(echo.HeaderContentType))
+
+	var body struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	p.Equal("invalid_transition", body.Code)
+	p.Equal("invalid status transition of order transition-04 from "+pkg.StatusOrderManagementProcessed+" to "+pkg.StatusOrderManagementCancelled, body.Detail)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("transition-04"))
+}
+
+// changeStatusBeforeCancel moves the order to the given status as it is about to
+// be cancelled.
+func (p *TransitionTestSuite) changeStatusBeforeCancel(status string) {
+	p.racing.beforeCancel = func(orderID string) {
+		p.NoError(repository.DB().Table("orders").Where("order_id = ?", orderID).Update("status", status).Error)
+	}
+}
+
+func (p *TransitionTestSuite) TestCancelWhileStatusChanges() {
+	for _, change := range []struct{ from, to string }{
+		{pkg.StatusOrderManagementPending, pkg.StatusOrderManagementRunning},
+		{pkg.StatusOrderManagementScheduled, pkg.StatusOrderManagementRunning},
+		{pkg.StatusOrderManagementRunning, pkg.StatusOrderManagementScheduled},
+	} {
+		orderID := "transition-" + change.from + "-" + change.to
+		p.storeOrder(orderID, change.from)
+		p.changeStatusBeforeCancel(change.to)
+
+		err := p.racingService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: orderID, Actor: "test"})
+		p.NoError(err, orderID)
+		p.Equal(pkg.StatusOrderManagementCancelled, p.status(orderID))
+
+		// The order is cancelled from the status it had by then
+		res, err := p.repositoryService.ListOrderEvents(p.ctx, dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+		p.NoError(err)
+		p.Require().NotEmpty(res.Events)
+		last := res.Events[len(res.Events)-1]
+		p.Equal(change.to, *last.FromStatus, orderID)
+		p.Equal(pkg.StatusOrderManagementCancelled, *last.ToStatus, orderID)
+	}
+}
+
+func (p *TransitionTestSuite) TestCancelAfterProcessingEndedMeanwhile() {
+	p.storeOrder("transition-07", pkg.StatusOrderManagementRunning)
+	p.changeStatusBeforeCancel(pkg.StatusOrderManagementProcessed)
+
+	err := p.racingService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: "transition-07", Actor: "test"})
+	p.requireTransitionError(err, "transition-07", pkg.StatusOrderManagementProcessed, pkg.StatusOrderManagementCancelled)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("transition-07"))
+}
+
+func (p *TransitionTestSuite) TestCancelUnknownOrder() {
+	err := p.orderService.CancelOrder(p.ctx, dto.CancelOrderUsecaseRequest{OrderID: "transition-08", Actor: "test"})
+	p.ErrorIs(err, pkg.ErrNotFound)
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// WaitTestSuite looks orders up with ?wait= while a worker processes them. The
+// worker finishes an order once the gate is opened for it.
+type WaitTestSuite struct {
+	workerSuite
+	gate   chan struct{}
+	server *echo.Echo
+}
+
+func (p *WaitTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "wait",
+		WorkerCount:         1,
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+		ShutdownTimeout:     1,
+	}, gatedProcess{gate: p.gate})
+	// Order lookups need no webhooks
+	p.server = newServer(p.orderService, nil)
+}
+
+func TestWait(t *testing.T) {
+	suite.Run(t, new(WaitTestSuite))
+}
+
+// startOrder creates an order and waits until the worker processes it.
+func (p *WaitTestSuite) startOrder(orderID string) {
+	p.createOrder(orderID, pkg.PriorityOrderManagementNormal)
+	p.waitStatus(orderID, pkg.StatusOrderManagementRunning)
+}
+
+// waitOrder looks the order up with the given wait, in the background. The
+// response is sent to the returned channel.
+func (p *WaitTestSuite) waitOrder(ctx context.Context, orderID string, wait string) <-chan *httptest.ResponseRecorder {
+	res := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+orderID+"?wait="+wait, nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		p.server.ServeHTTP(rec, req)
+		res <- rec
+	}()
+	return res
+}
+
+// orderStatus returns the status of the order in a lookup response.
+func (p *WaitTestSuite) orderStatus(rec *httptest.ResponseRecorder) string {
+	var body struct {
+		Status string `json:"status"`
+	}
+	p.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Status
+}
+
+func (p *WaitTestSuite) TestWaitReturnsWhenProcessingEnds() {
+	p.startOrder("wait-01")
+
+	res := p.waitOrder(context.Background(), "wait-01", "30s")
+	select {
+	case <-res:
+		p.FailNow("lookup returned while the order was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.gate <- struct{}{}
+	select {
+	case rec := <-res:
+		p.Equal(http.StatusOK, rec.Code)
+		p.Equal(pkg.StatusOrderManagementProcessed, p.orderStatus(rec))
+	case <-time.After(time.Second):
+		p.Fail("lookup still waiting after the order was processed")
+	}
+}
+
+func (p *WaitTestSuite) TestWaitReturnsEndedOrderAtOnce() {
+	p.startOrder("wait-02")
+	p.gate <- struct{}{}
+	p.waitStatus("wait-02", pkg.StatusOrderManagementProcessed)
+
+	start := time.Now()
+	rec := <-p.waitOrder(context.Background(), "wait-02", "30")
+	p.Less(time.Since(start), time.Second)
+	p.Equal(http.StatusOK, rec.Code)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.orderStatus(rec))
+}
+
+func (p *WaitTestSuite) TestWaitElapses() {
+	p.startOrder("wait-03")
+	defer func() { p.gate <- struct{}{} }()
+
+	start := time.Now()
+	rec := <-p.waitOrder(context.Background(), "wait-03", "200ms")
+	p.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+	p.Equal(http.StatusAccepted, rec.Code)
+	p.Equal(pkg.StatusOrderManagementRunning, p.orderStatus(rec))
+}
+
+func (p *WaitTestSuite) TestWaitRejectsInvalidDuration() {
+	for _, wait := range []string{"soon", "-1s", "61s", "61", "2m"} {
+		rec := serve(p.server, http.MethodGet, "/api/v1/orders/wait-04?wait="+wait, nil)
+		p.Equal(http.StatusBadRequest, rec.Code, wait)
+	}
+}
+
+func (p *WaitTestSuite) TestWaitEndsWithClientDisconnect() {
+	p.startOrder("wait-05")
+	defer func() { p.gate <- struct{}{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := p.waitOrder(ctx, "wait-05", "30s")
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case rec := <-res:
+		// The disconnected client is not answered, nor the request logged as failed
+		p.Empty(rec.Body.String())
+	case <-time.After(time.Second):
+		p.Fail("lookup still waiting after the client disconnected")
+	}
+}
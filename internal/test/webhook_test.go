@@ -0,0 +1,240 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/sink"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// WebhookTestSuite delivers order events to a local receiver, relaying them from
+// the outbox to the webhook sink. Every test starts without orders or webhooks
+// in a database file of its own.
+type WebhookTestSuite struct {
+	ctx               context.Context
+	repositoryService interfaces.OrderRepository
+	webhookService    interfaces.WebhookUseCase
+	outboxRelay       interfaces.OutboxRelay
+
+	suite.Suite
+}
+
+func (p *WebhookTestSuite) SetupSuite() {
+	cfg := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig: config.ServiceConfig{
+			WebhookPollInterval: 1,
+			WebhookTimeout:      5,
+			WebhookMaxAttempts:  3,
+		},
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(cfg.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.webhookService = usecase.NewWebhookUseCase(cfg, repositoryService)
+	p.outboxRelay = usecase.NewOutboxRelay(cfg, repositoryService, sink.NewWebhookSink(repositoryService))
+	p.ctx = context.Background()
+}
+
+func (p *WebhookTestSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *WebhookTestSuite) SetupTest() {
+	for _, table := range []string{"orders", "order_events", "outbox", "webhook_subscriptions", "webhook_deliveries"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+// webhookReceiver records the webhook requests it receives, answering each with
+// the next of its status codes and 200 once they are used up.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *webhookReceiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// createOrder stores a pending order, which records its creation event.
+func (p *WebhookTestSuite) createOrder(orderID string) {
+	priority := pkg.PriorityLevels[pkg.PriorityOrderManagementNormal]
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(p.ctx, dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &priority,
+		Status:         &pkg.StatusOrderManagementPending,
+		ProcessingTime: &processingTime,
+	}})
+	p.NoError(err)
+}
+
+// dispatch runs the outbox relay and the webhook dispatcher until the returned
+// function is called.
+func (p *WebhookTestSuite) dispatch() (stop func()) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = p.outboxRelay.RelayOutbox(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		_ = p.webhookService.DispatchWebhooks(ctx)
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// unpublished returns the number of outbox messages not relayed yet.
+func (p *WebhookTestSuite) unpublished() int64 {
+	var count int64
+	p.NoError(repository.DB().Model(&dto.BaseOutboxMessage{}).Where("published_at IS NULL").Count(&count).Error)
+	return count
+}
+
+// deliveries returns the delivery log of a webhook.
+func (p *WebhookTestSuite) deliveries(webhookID string) []dto.BaseWebhookDelivery {
+	res, err := p.webhookService.ListWebhookDeliveries(p.ctx, dto.ListWebhookDeliveriesUsecaseRequest{WebhookID: webhookID})
+	p.NoError(err)
+	return res.Deliveries
+}
+
+func (p *WebhookTestSuite) TestDeliverSignedPayload() {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := p.webhookService.CreateWebhook(p.ctx, dto.CreateWebhookUsecaseRequest{URL: server.URL})
+	p.NoError(err)
+	p.NotEmpty(webhook.Secret)
+
+	p.createOrder("webhook-01")
+
+	stop := p.dispatch()
+	p.Eventually(func() bool { return receiver.received() == 1 }, 5*time.Second, 50*time.Millisecond)
+	stop()
+
+	req, body := receiver.requests[0], receiver.bodies[0]
+	timestamp, err := strconv.ParseInt(req.Header.Get(pkg.WebhookTimestampHeader), 10, 64)
+	p.NoError(err)
+	p.True(pkg.VerifyWebhookSignature(webhook.Secret, timestamp, body, req.Header.Get(pkg.WebhookSignatureHeader)))
+	p.False(pkg.VerifyWebhookSignature("another secret", timestamp, body, req.Header.Get(pkg.WebhookSignatureHeader)))
+
+	var payload map[string]interface{}
+	p.NoError(json.Unmarshal(body, &payload))
+	p.Equal("webhook-01", payload["order_id"])
+	p.Equal(pkg.StatusOrderManagementPending, payload["to_status"])
+	p.Equal(req.Header.Get(pkg.WebhookEventIDHeader), strconv.Itoa(int(payload["event_id"].(float64))))
+
+	deliveries := p.deliveries(webhook.ID)
+	p.Len(deliveries, 1)
+	p.Equal(pkg.StatusWebhookDeliveryDelivered, *deliveries[0].Status)
+	p.Equal(http.StatusOK, *deliveries[0].ResponseCode)
+	p.NotNil(deliveries[0].DeliveredAt)
+}
+
+func (p *WebhookTestSuite) TestRetryFailedDelivery() {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := p.webhookService.CreateWebhook(p.ctx, dto.CreateWebhookUsecaseRequest{URL: server.URL})
+	p.NoError(err)
+
+	p.createOrder("webhook-02")
+
+	// The backoff base is 0, so the retry is due at the next poll
+	stop := p.dispatch()
+	p.Eventually(func() bool { return receiver.received() == 2 }, 5*time.Second, 50*time.Millisecond)
+	stop()
+
+	deliveries := p.deliveries(webhook.ID)
+	p.Len(deliveries, 1)
+	p.Equal(pkg.StatusWebhookDeliveryDelivered, *deliveries[0].Status)
+	p.Equal(2, *deliveries[0].Attempts)
+	p.Equal(receiver.requests[0].Header.Get(pkg.WebhookDeliveryIDHeader), receiver.requests[1].Header.Get(pkg.WebhookDeliveryIDHeader))
+}
+
+func (p *WebhookTestSuite) TestGiveUpAfterMaxAttempts() {
+	receiver := &webhookReceiver{statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := p.webhookService.CreateWebhook(p.ctx, dto.CreateWebhookUsecaseRequest{URL: server.URL})
+	p.NoError(err)
+
+	p.createOrder("webhook-03")
+
+	stop := p.dispatch()
+	p.Eventually(func() bool {
+		deliveries := p.deliveries(webhook.ID)
+		return len(deliveries) == 1 && *deliveries[0].Status == pkg.StatusWebhookDeliveryFailed
+	}, 8*time.Second, 100*time.Millisecond)
+	stop()
+
+	deliveries := p.deliveries(webhook.ID)
+	p.Equal(3, *deliveries[0].Attempts)
+	p.Equal(http.StatusBadGateway, *deliveries[0].ResponseCode)
+	p.Equal(3, receiver.received())
+}
+
+func (p *WebhookTestSuite) TestFilterByStatus() {
+	webhook, err := p.webhookService.CreateWebhook(p.ctx, dto.CreateWebhookUsecaseRequest{
+		URL:      "http://127.0.0.1:1",
+		Statuses: []string{pkg.StatusOrderManagementProcessed},
+	})
+	p.NoError(err)
+
+	p.createOrder("webhook-04")
+
+	stop := p.dispatch()
+	p.Eventually(func() bool { return p.unpublished() == 0 }, 5*time.Second, 50*time.Millisecond)
+	stop()
+
+	// Creating an order moves it to Pending, which the webhook does not receive
+	p.Empty(p.deliveries(webhook.ID))
+}
@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// WorkerPoolTestSuite pauses, drains, resumes and resizes the workers. Every test
+// starts with two running workers.
+type WorkerPoolTestSuite struct {
+	workerSuite
+	gate chan struct{}
+}
+
+func (p *WorkerPoolTestSuite) SetupSuite() {
+	p.gate = make(chan struct{})
+	p.setup(config.ServiceConfig{
+		InstanceID:          "workerpool",
+		WorkerCount:         2,
+		WorkerPollInterval:  1,
+		LeaseDuration:       30,
+		LeaseReaperInterval: 10,
+		MaxAttempts:         3,
+	}, gatedProcess{gate: p.gate})
+}
+
+func (p *WorkerPoolTestSuite) SetupTest() {
+	p.deleteOrders()
+
+	_, err := p.orderService.ResumeWorkers(context.Background(), dto.ControlWorkersUsecaseRequest{Actor: "test"})
+	p.NoError(err)
+	_, err = p.orderService.ResizeWorkers(context.Background(), dto.ResizeWorkersUsecaseRequest{Size: 2, Actor: "test"})
+	p.NoError(err)
+
+	p.startWorkers()
+}
+
+func TestWorkerPool(t *testing.T) {
+	suite.Run(t, new(WorkerPoolTestSuite))
+}
+
+func (p *WorkerPoolTestSuite) TestPauseKeepsAcceptingOrders() {
+	res, err := p.orderService.PauseWorkers(context.Background(), dto.ControlWorkersUsecaseRequest{Actor: "test"})
+	p.NoError(err)
+	p.Equal(pkg.StatusWorkerPoolPaused, res.State)
+	p.Equal("test", res.ChangedBy)
+
+	// Paused workers are neither woken nor poll for the new order
+	p.createOrder("paused", pkg.PriorityOrderManagementNormal)
+	time.Sleep(1500 * time.Millisecond)
+	p.Equal(pkg.StatusOrderManagementPending, p.status("paused"))
+
+	res, err = p.orderService.ResumeWorkers(context.Background(), dto.ControlWorkersUsecaseRequest{Actor: "test"})
+	p.NoError(err)
+	p.Equal(pkg.StatusWorkerPoolRunning, res.State)
+
+	p.Eventually(func() bool {
+		return p.status("paused") == pkg.StatusOrderManagementRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	p.gate <- struct{}{}
+	p.Eventually(func() bool {
+		return p.status("paused") == pkg.StatusOrderManagementProcessed
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (p *WorkerPoolTestSuite) TestDrainWaitsForOrdersInFlight() {
+	p.createOrder("in-flight", pkg.PriorityOrderManagementNormal)
+	p.Eventually(func() bool {
+		return p.status("in-flight") == pkg.StatusOrderManagementRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	res, err := p.orderService.DrainWorkers(context.Background(), dto.DrainWorkersUsecaseRequest{Actor: "test", Wait: 50 * time.Millisecond})
+	p.NoError(err)
+	p.Equal(pkg.StatusWorkerPoolDraining, res.State)
+	p.Equal(1, res.Busy)
+	p.Equal([]string{"in-flight"}, res.InFlight)
+
+	p.createOrder("queued", pkg.PriorityOrderManagementNormal)
+
+	drained := make(chan dto.WorkerPoolUsecaseResponse)
+	go func() {
+		res, err := p.orderService.DrainWorkers(context.Background(), dto.DrainWorkersUsecaseRequest{Actor: "test", Wait: 5 * time.Second})
+		p.NoError(err)
+		drained <- res
+	}()
+	p.gate <- struct{}{}
+
+	res = <-drained
+	p.Equal(pkg.StatusWorkerPoolPaused, res.State)
+	p.Zero(res.Busy)
+	p.Empty(res.InFlight)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("in-flight"))
+	p.Equal(pkg.StatusOrderManagementPending, p.status("queued"))
+
+	res, err = p.orderService.GetWorkerPool(context.Background())
+	p.NoError(err)
+	p.Equal(int64(1), res.Stats.Processed)
+	p.Positive(res.Stats.ProcessingTime)
+	p.GreaterOrEqual(res.Stats.Claims, int64(1))
+}
+
+// workerStates returns the state of every worker of the pool.
+func (p *WorkerPoolTestSuite) workerStates() []string {
+	res, err := p.orderService.GetWorkerPool(context.Background())
+	p.NoError(err)
+
+	states := make([]string, 0, len(res.Workers))
+	for _, worker := range res.Workers {
+		states = append(states, worker.State)
+	}
+	return states
+}
+
+func (p *WorkerPoolTestSuite) TestResizeRetiresWorkersAfterTheirOrder() {
+	p.Eventually(func() bool {
+		return len(p.workerStates()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	p.createOrder("in-flight", pkg.PriorityOrderManagementNormal)
+	p.Eventually(func() bool {
+		return p.status("in-flight") == pkg.StatusOrderManagementRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The idle worker stops right away, the busy one once its order is done
+	res, err := p.orderService.ResizeWorkers(context.Background(), dto.ResizeWorkersUsecaseRequest{Size: 0, Actor: "test", Reason: "incident"})
+	p.NoError(err)
+	p.Zero(res.Size)
+	p.Equal("test", res.ResizedBy)
+	p.Equal("incident", res.ResizeReason)
+	p.Eventually(func() bool {
+		states := p.workerStates()
+		return len(states) == 1 && states[0] == pkg.StatusWorkerRetiring
+	}, 5*time.Second, 10*time.Millisecond)
+
+	res, err = p.orderService.GetWorkerPool(context.Background())
+	p.NoError(err)
+	p.Equal("in-flight", *res.Workers[0].OrderID)
+
+	p.createOrder("queued", pkg.PriorityOrderManagementNormal)
+	p.gate <- struct{}{}
+	p.Eventually(func() bool {
+		return len(p.workerStates()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	p.Equal(pkg.StatusOrderManagementProcessed, p.status("in-flight"))
+	p.Equal(pkg.StatusOrderManagementPending, p.status("queued"))
+
+	// New workers pick up the waiting order
+	res, err = p.orderService.ResizeWorkers(context.Background(), dto.ResizeWorkersUsecaseRequest{Size: 3, Actor: "test"})
+	p.NoError(err)
+	p.Len(res.Workers, 3)
+	p.Eventually(func() bool {
+		return p.status("queued") == pkg.StatusOrderManagementRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	p.Contains(p.workerStates(), pkg.StatusWorkerBusy)
+	p.gate <- struct{}{}
+	p.Eventually(func() bool {
+		return p.status("queued") == pkg.StatusOrderManagementProcessed
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (p *WorkerPoolTestSuite) TestResizeRejectsInvalidSize() {
+	_, err := p.orderService.ResizeWorkers(context.Background(), dto.ResizeWorkersUsecaseRequest{Size: -1, Actor: "test"})
+	p.ErrorIs(err, pkg.ErrInvalidRequest)
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/internal/repository"
+	"github.com/seyedmo30/order_management/internal/usecase"
+	"github.com/seyedmo30/order_management/pkg"
+	"github.com/stretchr/testify/suite"
+)
+
+// instantProcess processes every order successfully without waiting.
+type instantProcess struct{}
+
+func (instantProcess) ProcessOrder(ctx context.Context, processingTime int) (string, error) {
+	return pkg.StatusOrderManagementProcessed, nil
+}
+
+// gatedProcess processes an order once its gate is opened, and fails it when its
+// context is done first.
+type gatedProcess struct {
+	gate chan struct{}
+}
+
+func (g gatedProcess) ProcessOrder(ctx context.Context, processingTime int) (string, error) {
+	select {
+	case <-g.gate:
+		return pkg.StatusOrderManagementProcessed, nil
+	case <-ctx.Done():
+		return pkg.StatusOrderManagementFailed, nil
+	}
+}
+
+// workerSuite is embedded by the suites that run the order workers. Every test
+// starts with the workers running and without orders in a database file of the
+// suite's own.
+type workerSuite struct {
+	ctx               context.Context
+	cancel            context.CancelFunc
+	done              chan error
+	repositoryService interfaces.OrderRepository
+	orderService      interfaces.OrderUseCase
+
+	suite.Suite
+}
+
+// setup creates the order use case of the suite, processing orders with process.
+func (p *workerSuite) setup(cfg config.ServiceConfig, process interfaces.Process) {
+	app := config.App{
+		DatabaseConfig: fileDatabaseConfig(p.T()),
+		ServiceConfig:  cfg,
+	}
+
+	repositoryService, err := repository.NewOrderManagementRepository(app.DatabaseConfig)
+	p.Require().NoError(err)
+	p.repositoryService = repositoryService
+	p.orderService = usecase.NewOrderUseCase(app, repositoryService, process)
+}
+
+func (p *workerSuite) TearDownSuite() {
+	p.NoError(repository.Close())
+}
+
+func (p *workerSuite) SetupTest() {
+	p.deleteOrders()
+	p.startWorkers()
+}
+
+func (p *workerSuite) TearDownTest() {
+	p.cancel()
+	<-p.done
+}
+
+// deleteOrders removes the orders, their events and outbox messages.
+func (p *workerSuite) deleteOrders() {
+	for _, table := range []string{"orders", "order_events", "outbox"} {
+		p.NoError(repository.DB().Exec("DELETE FROM " + table).Error)
+	}
+}
+
+// startWorkers runs the workers until p.cancel is called. Their result is sent
+// to p.done, which TearDownTest receives from.
+func (p *workerSuite) startWorkers() {
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = make(chan error, 1)
+	go func() {
+		p.done <- p.orderService.ProcessOrder(p.ctx)
+	}()
+}
+
+func (p *workerSuite) createOrder(orderID string, priority string) {
+	_, err := p.orderService.CreateOrder(context.Background(), dto.CreateOrderUsecaseRequest{BaseCreateOrderRequest: dto.BaseCreateOrderRequest{
+		OrderID:        orderID,
+		Priority:       priority,
+		ProcessingTime: 1,
+	}})
+	p.NoError(err)
+}
+
+// queueOrder stores a pending order straight in the repository, as if another
+// process had queued it, so that no worker is woken for it.
+func (p *workerSuite) queueOrder(orderID string, priority string) {
+	level := pkg.PriorityLevels[priority]
+	processingTime := 1
+	err := p.repositoryService.CreateOrder(context.Background(), dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
+		ID:             orderID,
+		OrderID:        &orderID,
+		Priority:       &level,
+		Status:         &pkg.StatusOrderManagementPending,
+		ProcessingTime: &processingTime,
+	}})
+	p.NoError(err)
+}
+
+func (p *workerSuite) order(orderID string) dto.GetOrderByIDRepositoryResponse {
+	order, err := p.repositoryService.GetOrderByID(context.Background(), orderID)
+	p.NoError(err)
+	return order
+}
+
+func (p *workerSuite) status(orderID string) string {
+	return *p.order(orderID).Status
+}
+
+func (p *workerSuite) waitStatus(orderID string, status string) {
+	p.Eventually(func() bool {
+		return p.status(orderID) == status
+	}, 5*time.Second, 10*time.Millisecond)
+}
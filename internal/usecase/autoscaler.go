@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// autoscalerActor is the actor the autoscaler resizes the worker pool as.
+const autoscalerActor = "autoscaler"
+
+// autoscaler is the concrete implementation of the Autoscaler interface. Every
+// interval it sizes the worker pool to drain the pending orders within the next
+// interval at the average processing time observed so far, and shrinks the pool
+// instead while claims hit database contention, which more workers would only
+// make worse. Without pending orders it retires half of the idle workers.
+type autoscaler struct {
+	config config.App
+	repo   interfaces.OrderRepository
+	pool   interfaces.WorkerPool
+	clock  pkg.Clock
+
+	// avgProcessing is the average processing time of the last interval that
+	// processed orders
+	avgProcessing time.Duration
+}
+
+// autoscaleSample is what the autoscaler observed over an interval. Stats only
+// counts the claims and orders of the interval.
+type autoscaleSample struct {
+	pool     dto.WorkerPoolUsecaseResponse
+	stats    dto.WorkerStats
+	pending  int64
+	interval time.Duration
+}
+
+// NewAutoscaler creates a new instance of autoscaler.
+func NewAutoscaler(config config.App, repo interfaces.OrderRepository, pool interfaces.WorkerPool, clock pkg.Clock) *autoscaler {
+	// Until orders were processed, assume they take as long as they may
+	avgProcessing := time.Duration(config.OrderProcessTimeout) * time.Second
+	if avgProcessing <= 0 {
+		avgProcessing = time.Duration(config.AutoscaleInterval) * time.Second
+	}
+	return &autoscaler{config: config, repo: repo, pool: pool, clock: clock, avgProcessing: avgProcessing}
+}
+
+// AutoscaleWorkers resizes the worker pool every interval until the context is
+// canceled.
+func (a *autoscaler) AutoscaleWorkers(ctx context.Context) error {
+	interval := time.Duration(max(a.config.AutoscaleInterval, 1)) * time.Second
+	lower, upper := a.bounds()
+	logger.Info("Starting worker autoscaler", "minWorkers", lower, "maxWorkers", upper, "interval", interval)
+
+	previous, err := a.pool.GetWorkerPool(ctx)
+	if err != nil {
+		return err
+	}
+	last := a.clock.Now()
+
+	for {
+		select {
+		case <-a.clock.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		now := a.clock.Now()
+		current, err := a.pool.GetWorkerPool(ctx)
+		if err != nil {
+			logger.Error("Autoscaler failed to read the worker pool", "error", err)
+			continue
+		}
+		pending, err := a.repo.CountPendingOrders(ctx)
+		if err != nil {
+			logger.Error("Autoscaler failed to count pending orders", "error", err)
+			continue
+		}
+
+		a.autoscale(ctx, autoscaleSample{
+			pool: current,
+			stats: dto.WorkerStats{
+				Claims:         current.Stats.Claims - previous.Stats.Claims,
+				Contended:      current.Stats.Contended - previous.Stats.Contended,
+				Processed:      current.Stats.Processed - previous.Stats.Processed,
+				ProcessingTime: current.Stats.ProcessingTime - previous.Stats.ProcessingTime,
+			},
+			pending:  pending,
+			interval: now.Sub(last),
+		})
+		previous, last = current, now
+	}
+}
+
+// autoscale resizes the pool as decided for the sample. A paused pool is left
+// alone, as its pending orders say nothing about the workers needed.
+func (a *autoscaler) autoscale(ctx context.Context, sample autoscaleSample) {
+	if sample.pool.State != pkg.StatusWorkerPoolRunning {
+		return
+	}
+
+	size, reason := a.decide(sample)
+	logger.Info("Autoscaler decision",
+		"size", sample.pool.Size, "target", size, "reason", reason,
+		"pending", sample.pending, "busy", sample.pool.Busy, "avgProcessing", a.avgProcessing,
+		"claims", sample.stats.Claims, "contended", sample.stats.Contended)
+	if size == sample.pool.Size {
+		return
+	}
+
+	_, err := a.pool.ResizeWorkers(ctx, dto.ResizeWorkersUsecaseRequest{Size: size, Actor: autoscalerActor, Reason: reason})
+	if err != nil {
+		logger.Error("Autoscaler failed to resize the worker pool", "size", size, "error", err)
+	}
+}
+
+// decide returns the pool size for the sample, within the bounds, and why.
+func (a *autoscaler) decide(sample autoscaleSample) (size int, reason string) {
+	if sample.stats.Processed > 0 {
+		a.avgProcessing = sample.stats.ProcessingTime / time.Duration(sample.stats.Processed)
+	}
+
+	size = sample.pool.Size
+	busy := sample.pool.Busy
+	switch {
+	case sample.stats.Claims > 0 && sample.stats.Contended*100 > sample.stats.Claims*int64(a.config.AutoscaleMaxContention):
+		size--
+		reason = fmt.Sprintf("%d of %d claims hit database contention", sample.stats.Contended, sample.stats.Claims)
+	case sample.pending > 0:
+		// Workers needed to process the pending orders within an interval
+		interval := max(sample.interval, time.Second)
+		needed := int((time.Duration(sample.pending)*a.avgProcessing + interval - 1) / interval)
+		size = max(size, needed)
+		reason = fmt.Sprintf("%d pending orders at %s each", sample.pending, a.avgProcessing)
+	case busy < size:
+		size -= max((size-busy)/2, 1)
+		reason = fmt.Sprintf("no pending orders, %d of %d workers busy", busy, sample.pool.Size)
+	default:
+		reason = "no pending orders, every worker busy"
+	}
+
+	lower, upper := a.bounds()
+	switch {
+	case size < lower:
+		size = lower
+	case size > upper:
+		size = upper
+	}
+	return size, reason
+}
+
+// bounds returns the configured worker limits, kept within what the pool allows.
+func (a *autoscaler) bounds() (lower, upper int) {
+	lower = min(max(a.config.AutoscaleMinWorkers, 0), maxWorkers)
+	upper = min(max(a.config.AutoscaleMaxWorkers, lower), maxWorkers)
+	return lower, upper
+}
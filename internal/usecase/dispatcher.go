@@ -0,0 +1,29 @@
+package usecase
+
+// orderDispatcher wakes idle workers when orders may have become claimable. It
+// holds up to one pending wake-up per worker, so a wake-up is never lost: when
+// every slot is taken, every worker will check for claimable orders again before
+// it idles.
+type orderDispatcher struct {
+	wakeups chan struct{}
+}
+
+func newOrderDispatcher(workerCount int) *orderDispatcher {
+	return &orderDispatcher{wakeups: make(chan struct{}, max(workerCount, 1))}
+}
+
+// notify wakes up to count idle workers, without blocking.
+func (d *orderDispatcher) notify(count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case d.wakeups <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// wait returns the channel an idle worker receives its wake-up from.
+func (d *orderDispatcher) wait() <-chan struct{} {
+	return d.wakeups
+}
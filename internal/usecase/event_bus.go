@@ -0,0 +1,64 @@
+package usecase
+
+import "sync"
+
+// orderEventBus wakes the event streams of this process when an order changes.
+// It carries no events itself: a woken stream reads the events it has not seen
+// yet from the repository, so a stream never misses an event, however many
+// notifications are coalesced.
+type orderEventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]string // Order ID a subscriber follows, or "" for every order
+}
+
+func newOrderEventBus() *orderEventBus {
+	return &orderEventBus{subscribers: make(map[chan struct{}]string)}
+}
+
+// subscribe returns a channel that is signalled when the given order, or any
+// order if orderID is empty, changes.
+func (b *orderEventBus) subscribe(orderID string) (notify <-chan struct{}, unsubscribe func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.subscribers[ch] = orderID
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish signals the subscribers of the given orders. Without order IDs every
+// subscriber is signalled.
+func (b *orderEventBus) publish(orderIDs ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, following := range b.subscribers {
+		if !b.follows(following, orderIDs) {
+			continue
+		}
+		// A pending signal already wakes the subscriber
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// follows reports whether a subscriber following the given order is interested
+// in a change of orderIDs.
+func (b *orderEventBus) follows(following string, orderIDs []string) bool {
+	if following == "" || len(orderIDs) == 0 {
+		return true
+	}
+	for _, orderID := range orderIDs {
+		if orderID == following {
+			return true
+		}
+	}
+	return false
+}
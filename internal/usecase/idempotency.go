@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// hashCreateOrderRequest identifies the content of an order creation request,
+// regardless of how its JSON was formatted.
+func hashCreateOrderRequest(req dto.BaseCreateOrderRequest) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyKey builds the key to store with the order a request creates.
+func (u *orderUseCase) idempotencyKey(req dto.CreateOrderUsecaseRequest, requestHash string) *dto.BaseIdempotencyKey {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(u.config.IdempotencyKeyTTL) * time.Hour)
+	responseBody := string(req.Response.Body)
+	return &dto.BaseIdempotencyKey{
+		Key:          req.IdempotencyKey,
+		RequestHash:  &requestHash,
+		OrderID:      &req.OrderID,
+		ResponseCode: &req.Response.Code,
+		ResponseBody: &responseBody,
+		ExpiresAt:    &expiresAt,
+		CreatedAt:    &now,
+	}
+}
+
+// replayIdempotentRequest returns the response stored with an idempotency key,
+// marked as Replayed, if the key is in use. Using it for a different request is a
+// 422 error.
+func (u *orderUseCase) replayIdempotentRequest(ctx context.Context, key, requestHash string) (res dto.CreateOrderUsecaseResponse, err error) {
+	stored, err := u.repo.GetIdempotencyKey(ctx, dto.GetIdempotencyKeyRepositoryRequest{Key: key, Now: time.Now()})
+	if errors.Is(err, pkg.ErrNotFound) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if *stored.RequestHash != requestHash {
+		return res, pkg.ErrIdempotencyKeyReused
+	}
+
+	logger.Info("Replaying response of idempotent request", "orderID", *stored.OrderID)
+	res.Response = dto.IdempotentResponse{Code: *stored.ResponseCode, Body: []byte(*stored.ResponseBody)}
+	res.Replayed = true
+	return res, nil
+}
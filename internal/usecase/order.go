@@ -3,8 +3,12 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
@@ -18,101 +22,438 @@ import (
 // GetLogger initializes the global logger instance
 var logger = pkg.GetLogger()
 
+// Causes reported by the context of an order whose processing was aborted.
+var (
+	errOrderCancelled = errors.New("order cancelled")
+	errOrderLockLost  = errors.New("order lock lost")
+	errShuttingDown   = errors.New("shutting down")
+)
+
 // orderUseCase is the concrete implementation of the OrderUseCase interface.
-// It is responsible for creating orders and triggering the processing worker through a signal.
+// It is responsible for creating orders and waking the processing workers through a dispatcher.
 type orderUseCase struct {
-	config             config.App
-	repo               interfaces.OrderRepository
-	process            interfaces.Process
-	orderCreatedSignal chan struct{} // Signal to notify worker when an order is created
+	config     config.App
+	repo       interfaces.OrderRepository
+	process    interfaces.Process
+	dispatcher *orderDispatcher // Wakes idle shared workers when orders are queued
+	gate       *workerGate      // Pauses the workers and tracks the busy ones
+	pool       *workerPool      // Starts and retires workers to keep the pool at its size
+	stats      workerStats      // Counts claims and processed orders for the autoscaler
+	instanceID string           // Prefix of the lock owner of every worker in this process
+	events     *orderEventBus   // Wakes event streams when an order changes
+
+	// Wakes idle reserved workers, keyed by the lowest priority level they claim
+	reserved map[int]*orderDispatcher
+
+	mu       sync.Mutex
+	inFlight map[string]context.CancelCauseFunc // Aborts the processing of an order, keyed by order ID
 }
 
 // NewOrderUseCase creates a new instance of orderUseCase.
-// It initializes the order repository and the signal channel for worker triggering.
+// It initializes the order repository and the dispatcher waking the workers.
 func NewOrderUseCase(config config.App, repo interfaces.OrderRepository, process interfaces.Process) *orderUseCase {
-	// Create a buffered channel to send a signal when an order is created.
-	// This acts as an "observer" to trigger the worker to process the newly created order.
-	orderCreatedSignal := make(chan struct{}, 1)
+	// The instance ID lets a restarted process recognise the leases it held before.
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = uuid.NewString()
+		}
+		instanceID = hostname
+	}
 
-	logger.Info("NewOrderUseCase instance created")
-	return &orderUseCase{config: config, repo: repo, orderCreatedSignal: orderCreatedSignal, process: process}
+	logger.Info("NewOrderUseCase instance created", "instanceID", instanceID)
+	u := &orderUseCase{
+		config:     config,
+		repo:       repo,
+		dispatcher: newOrderDispatcher(maxWorkers),
+		gate:       newWorkerGate(),
+		process:    process,
+		instanceID: instanceID,
+		events:     newOrderEventBus(),
+		inFlight:   make(map[string]context.CancelCauseFunc),
+	}
+	u.pool = newWorkerPool(config.WorkerCount, config.WorkerReservations, u.lockOwner)
+	u.reserved = make(map[int]*orderDispatcher, len(u.pool.reservations))
+	for _, reservation := range u.pool.reservations {
+		u.reserved[reservation.level] = newOrderDispatcher(reservation.workers)
+	}
+	return u
 }
 
 // CreateOrder processes the order and queues it.
-// After creating the order in the repository, it triggers the worker to process it by sending a signal.
-func (u *orderUseCase) CreateOrder(ctx context.Context, req dto.CreateOrderUsecaseRequest) error {
+// After creating the order in the repository, it wakes a worker to process it.
+// A request repeating an idempotency key gets the response stored with the key instead.
+func (u *orderUseCase) CreateOrder(ctx context.Context, req dto.CreateOrderUsecaseRequest) (dto.CreateOrderUsecaseResponse, error) {
+
+	priority, ok := pkg.PriorityLevel(req.Priority)
+	if !ok {
+		return dto.CreateOrderUsecaseResponse{}, fmt.Errorf("unknown priority %q", req.Priority)
+	}
 
 	// Construct the repository request for creating the order
-	creatOrderRepositoryRequest := dto.CreatOrderRepositoryRequest{BaseOrder: dto.BaseOrder{
-		ID:             uuid.NewString(),
-		OrderID:        &req.OrderID,
-		Priority:       &req.Priority,
-		Status:         &pkg.StatusOrderManagementPending,
-		ProcessingTime: &req.ProcessingTime,
-	}}
+	creatOrderRepositoryRequest := dto.CreatOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			ID:             uuid.NewString(),
+			OrderID:        &req.OrderID,
+			Priority:       &priority,
+			Status:         &pkg.StatusOrderManagementPending,
+			ProcessingTime: &req.ProcessingTime,
+		},
+		Actor: req.Actor,
+	}
+
+	if req.IdempotencyKey != "" {
+		requestHash := hashCreateOrderRequest(req.BaseCreateOrderRequest)
+		if res, err := u.replayIdempotentRequest(ctx, req.IdempotencyKey, requestHash); res.Replayed || err != nil {
+			return res, err
+		}
+		creatOrderRepositoryRequest.IdempotencyKey = u.idempotencyKey(req, requestHash)
+	}
 
 	// Call the repository method to persist the order
 	err := u.repo.CreateOrder(ctx, creatOrderRepositoryRequest)
 	if err != nil {
+		// A concurrent request with the same key may have stored it first
+		if req.IdempotencyKey != "" && errors.Is(err, pkg.ErrDuplicateEntry) {
+			requestHash := *creatOrderRepositoryRequest.IdempotencyKey.RequestHash
+			if res, replayErr := u.replayIdempotentRequest(ctx, req.IdempotencyKey, requestHash); res.Replayed || replayErr != nil {
+				return res, replayErr
+			}
+		}
 		logger.Error("Failed to create order", "error", err)
-		return err
+		return dto.CreateOrderUsecaseResponse{}, err
 	}
 
 	// Log successful order creation
 	logger.Info("Order created successfully", "orderID", req.OrderID)
+	u.events.publish(req.OrderID)
+	u.wakeWorkers(priority)
+	return dto.CreateOrderUsecaseResponse{Response: req.Response}, nil
+}
 
-	// Attempt to send a signal to notify the worker to process the order.
-	// This operation is non-blocking; if the channel is full, it won't block the execution.
-	select {
-	case u.orderCreatedSignal <- struct{}{}:
-		// Signal sent successfully, worker will process the order
-		logger.Info("Signal sent to worker to process order", "orderID", req.OrderID)
-	default:
-		// If the channel is full, we simply don't send the signal to avoid deadlock
-		// and continue with the execution.
-		logger.Warn("Order creation signal channel full, no signal sent", "orderID", req.OrderID)
-	}
-	// Return response (just echoing the OrderID and success message here)
-	return nil
+// CreateOrders queues the orders of a batch in one transaction and wakes as many
+// workers as there are new orders. An order whose ID repeats an earlier order of
+// the batch or an existing order is not created, and reported as a duplicate.
+func (u *orderUseCase) CreateOrders(ctx context.Context, req dto.CreateOrdersUsecaseRequest) (dto.CreateOrdersUsecaseResponse, error) {
+	res := dto.CreateOrdersUsecaseResponse{Results: make([]dto.CreateOrderResult, len(req.Orders))}
+
+	orders := make([]dto.BaseOrder, 0, len(req.Orders))
+	seen := make(map[string]bool, len(req.Orders))
+	for i, order := range req.Orders {
+		res.Results[i].OrderID = order.OrderID
+
+		priority, ok := pkg.PriorityLevel(order.Priority)
+		if !ok {
+			res.Results[i].Status = pkg.StatusBatchItemInvalid
+			res.Results[i].Error = fmt.Sprintf("unknown priority %q", order.Priority)
+			continue
+		}
+		if seen[order.OrderID] {
+			res.Results[i].Status = pkg.StatusBatchItemDuplicate
+			res.Results[i].Error = "order_id repeats an earlier order of the batch"
+			continue
+		}
+		seen[order.OrderID] = true
+
+		orders = append(orders, dto.BaseOrder{
+			ID:             uuid.NewString(),
+			OrderID:        &order.OrderID,
+			Priority:       &priority,
+			Status:         &pkg.StatusOrderManagementPending,
+			ProcessingTime: &order.ProcessingTime,
+		})
+	}
+
+	var created []string
+	var priorities []int
+	if len(orders) > 0 {
+		repoRes, err := u.repo.CreateOrders(ctx, dto.CreateOrdersRepositoryRequest{Orders: orders, Actor: req.Actor})
+		if err != nil {
+			logger.Error("Failed to create orders", "error", err)
+			return dto.CreateOrdersUsecaseResponse{}, err
+		}
+
+		duplicates := make(map[string]bool, len(repoRes.Duplicates))
+		for _, orderID := range repoRes.Duplicates {
+			duplicates[orderID] = true
+		}
+		for i := range res.Results {
+			result := &res.Results[i]
+			switch {
+			case result.Status != "":
+			case duplicates[result.OrderID]:
+				result.Status = pkg.StatusBatchItemDuplicate
+				result.Error = pkg.DuplicateEntryRepositoryMessage
+			default:
+				result.Status = pkg.StatusBatchItemCreated
+				created = append(created, result.OrderID)
+				priority, _ := pkg.PriorityLevel(req.Orders[i].Priority)
+				priorities = append(priorities, priority)
+			}
+		}
+	}
+
+	logger.Info("Order batch created", "orders", len(req.Orders), "created", len(created))
+	if len(created) == 0 {
+		return res, nil
+	}
+	u.events.publish(created...)
+	u.wakeWorkers(priorities...)
+	return res, nil
 }
 
-// ProcessOrder handles worker creation and processing of orders.
+// ProcessOrder handles worker creation and processing of orders. Once ctx is done
+// the workers stop claiming orders, and ProcessOrder returns when the orders being
+// processed have finished or the shutdown timeout has passed. Orders still
+// unfinished then are returned to the queue.
 func (u *orderUseCase) ProcessOrder(ctx context.Context) error {
-	workerCount := u.config.WorkerCount
-	var wg sync.WaitGroup
-	wg.Add(workerCount)
+	// Recover work left behind by a previous run before the workers start claiming
+	if err := u.recoverOrders(ctx); err != nil {
+		return err
+	}
 
 	// Log the start of the worker pool
-	logger.Info("Starting order processing workers", "workerCount", workerCount)
+	reserved := u.pool.reserved()
+	logger.Info("Starting order processing workers", "workerCount", u.pool.currentSize(), "reservedWorkers", reserved)
+
+	// Return expired leases of crashed workers to the queue
+	go u.reapExpiredLocks(ctx)
 
-	// Start the workers
-	for i := 0; i < workerCount; i++ {
-		go func(workerID int) {
+	// Orders being processed outlive ctx until the shutdown timeout passes
+	drainCtx, abort := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer abort(nil)
+	workersDone := make(chan struct{})
+	go u.abortAfterShutdownTimeout(ctx, workersDone, abort)
+
+	// Start the workers; they begin with the orders already waiting. Resizing the
+	// pool starts and retires workers until ctx is done.
+	var wg sync.WaitGroup
+	u.pool.run(func(w *worker) {
+		wg.Add(1)
+		go func() {
 			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					// Context canceled, stop worker
-					logger.Info("Worker context canceled, stopping worker", "workerID", workerID)
-					return
-				case <-u.orderCreatedSignal:
-					// Signal received, process an order
-					logger.Info("Worker received signal to process order", "workerID", workerID)
-					if err := u.processSingleOrder(ctx); err != nil {
-						// Handle error if needed (e.g., log it)
-						logger.Error("Error processing order", "workerID", workerID, "error", err)
-					}
-				}
-			}
-		}(i)
-	}
+			defer u.pool.remove(w)
+			u.runWorker(ctx, drainCtx, w)
+		}()
+	})
 
 	// Wait for all workers to finish (only ends if the context is canceled)
+	<-ctx.Done()
+	u.pool.stop()
 	wg.Wait()
+	close(workersDone)
 	logger.Info("All workers finished processing orders")
+
+	// Return the orders whose processing was aborted to the queue
+	released, err := u.repo.ReleaseOrphanedLocks(context.WithoutCancel(ctx), dto.ReleaseOrphanedLocksRepositoryRequest{
+		InstanceID: u.instanceID,
+		Now:        time.Now(),
+		Transition: releaseTransition(u.instanceID+":shutdown", "unfinished at shutdown"),
+	})
+	if err != nil {
+		logger.Error("Failed to release order locks on shutdown", "error", err)
+		return err
+	}
+	if released > 0 {
+		logger.Warn("Returned unfinished orders to the queue", "released", released)
+		u.events.publish()
+	}
+	return nil
+}
+
+// abortAfterShutdownTimeout aborts the orders still being processed when the
+// workers have not finished within the shutdown timeout after ctx is done.
+func (u *orderUseCase) abortAfterShutdownTimeout(ctx context.Context, workersDone <-chan struct{}, abort context.CancelCauseFunc) {
+	select {
+	case <-ctx.Done():
+	case <-workersDone:
+		return
+	}
+
+	logger.Info("Draining orders in flight", "timeout", u.shutdownTimeout())
+	deadline := time.NewTimer(u.shutdownTimeout())
+	defer deadline.Stop()
+
+	select {
+	case <-deadline.C:
+		logger.Warn("Shutdown timeout passed, aborting orders in flight")
+		abort(errShuttingDown)
+	case <-workersDone:
+	}
+}
+
+// runWorker claims and processes orders until none is claimable or the pool is
+// paused, then idles until it is woken or the poll interval elapses. It stops
+// claiming once ctx is done or the worker is retired, while the order it is
+// processing runs until drainCtx is done.
+func (u *orderUseCase) runWorker(ctx, drainCtx context.Context, w *worker) {
+	poll := time.NewTicker(time.Duration(max(u.config.WorkerPollInterval, 1)) * time.Second)
+	defer poll.Stop()
+
+	// Reserved workers are only woken for the orders they may claim, so that they
+	// do not take the wake-ups of the shared workers or of other reservations
+	wakeups := u.dispatcher.wait()
+	if w.minPriority > 0 {
+		wakeups = u.reserved[w.minPriority].wait()
+	}
+
+	for {
+		for ctx.Err() == nil && !w.retired() && u.gate.enter() {
+			claimed, err := u.processSingleOrder(drainCtx, w)
+			u.gate.leave()
+			if err != nil {
+				// Idle before trying again, rather than spinning on a failing repository
+				logger.Error("Error processing order", "workerID", w.id, "error", err)
+				break
+			}
+			if !claimed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Worker context canceled, stopping worker", "workerID", w.id)
+			return
+		case <-w.retire:
+			logger.Info("Worker retired", "workerID", w.id)
+			return
+		case <-wakeups:
+		case <-poll.C:
+		}
+	}
+}
+
+// recoverOrders releases locks orphaned by a previous run and logs how many
+// pending orders are waiting to be processed.
+func (u *orderUseCase) recoverOrders(ctx context.Context) error {
+	released, err := u.repo.ReleaseOrphanedLocks(ctx, dto.ReleaseOrphanedLocksRepositoryRequest{
+		InstanceID: u.instanceID,
+		Now:        time.Now(),
+		Transition: releaseTransition(u.instanceID+":recovery", "lease expired or held by a previous run"),
+	})
+	if err != nil {
+		logger.Error("Failed to release orphaned order locks", "error", err)
+		return err
+	}
+
+	pending, err := u.repo.CountPendingOrders(ctx)
+	if err != nil {
+		logger.Error("Failed to count pending orders", "error", err)
+		return err
+	}
+
+	if released > 0 {
+		u.events.publish()
+	}
+
+	logger.Info("Order recovery completed", "releasedLocks", released, "pendingOrders", pending)
 	return nil
 }
 
+// reapExpiredLocks periodically releases leases that were not renewed in time and
+// wakes workers to pick the released orders up again.
+func (u *orderUseCase) reapExpiredLocks(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(max(u.config.LeaseReaperInterval, 1)) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			released, err := u.repo.ReleaseOrphanedLocks(ctx, dto.ReleaseOrphanedLocksRepositoryRequest{
+				Now:        time.Now(),
+				Transition: releaseTransition(u.instanceID+":lease-reaper", "lease expired"),
+			})
+			if err != nil {
+				logger.Error("Failed to release expired order locks", "error", err)
+				continue
+			}
+			if released > 0 {
+				logger.Warn("Released expired order locks", "released", released)
+				u.events.publish()
+				u.wakeAllWorkers(int(released))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// priorityAging returns the configured aging policy for waiting orders.
+func (u *orderUseCase) priorityAging() pkg.PriorityAging {
+	return pkg.PriorityAging{
+		Interval: time.Duration(u.config.PriorityAgingInterval) * time.Second,
+		Step:     u.config.PriorityAgingStep,
+	}
+}
+
+// lockOwner returns the lock owner identifier of the given worker.
+func (u *orderUseCase) lockOwner(workerID int) string {
+	return fmt.Sprintf("%s:worker-%d", u.instanceID, workerID)
+}
+
+// wakeWorkers wakes an idle shared worker for every order queued with the given
+// priority levels, and an idle reserved worker of every reservation that claims it.
+func (u *orderUseCase) wakeWorkers(priorities ...int) {
+	u.dispatcher.notify(len(priorities))
+	for level, dispatcher := range u.reserved {
+		count := 0
+		for _, priority := range priorities {
+			if priority >= level {
+				count++
+			}
+		}
+		dispatcher.notify(count)
+	}
+}
+
+// wakeAllWorkers wakes up to count idle workers of every kind, for orders whose
+// priority is not known.
+func (u *orderUseCase) wakeAllWorkers(count int) {
+	u.dispatcher.notify(count)
+	for _, dispatcher := range u.reserved {
+		dispatcher.notify(count)
+	}
+}
+
+// shutdownTimeout returns how long orders in flight may finish on shutdown.
+func (u *orderUseCase) shutdownTimeout() time.Duration {
+	return time.Duration(u.config.ShutdownTimeout) * time.Second
+}
+
+// leaseDuration returns how long a lock is held without being renewed, at least
+// a second.
+func (u *orderUseCase) leaseDuration() time.Duration {
+	return time.Duration(max(u.config.LeaseDuration, 1)) * time.Second
+}
+
+// renewLock extends the lease on the order until ctx is done. If the lease is lost,
+// cancel is called so that the worker stops processing an order it no longer owns.
+func (u *orderUseCase) renewLock(ctx context.Context, cancel context.CancelCauseFunc, orderID *string, owner string) {
+	ticker := time.NewTicker(u.leaseDuration() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			expiresAt := time.Now().Add(u.leaseDuration())
+			err := u.repo.RenewOrderLock(ctx, dto.RenewOrderLockRepositoryRequest{BaseOrder: dto.BaseOrder{
+				OrderID:       orderID,
+				LockOwner:     &owner,
+				LockExpiresAt: &expiresAt,
+			}})
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Failed to renew order lock, aborting processing", "orderID", orderID, "owner", owner, "error", err)
+				cancel(errOrderLockLost)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // ListAggregateOrderReport logs the order status counts every 2 seconds.
 func (u *orderUseCase) ListAggregateOrderReport(ctx context.Context) error {
 	reportInterval := u.config.ReportInterval
@@ -143,68 +484,522 @@ func (u *orderUseCase) GetOrder(ctx context.Context, orderID string) (res dto.Ge
 		return dto.GetOrderUsecaseResponse{}, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	// Map repository response to use case response (you can add more transformation logic here if needed)
-	res = dto.GetOrderUsecaseResponse{
-		BaseOrder: repoRes.BaseOrder,
+	// Map repository response to use case response
+	return u.orderResponse(repoRes.BaseOrder, time.Now()), nil
+}
+
+// WaitOrder returns the order once its processing has ended, or as it is when
+// req.Wait elapses. The order is only read again when this process changes it,
+// so orders processed by another instance are seen when the wait elapses.
+func (u *orderUseCase) WaitOrder(ctx context.Context, req dto.WaitOrderUsecaseRequest) (res dto.WaitOrderUsecaseResponse, err error) {
+	// Subscribe before the first read, so that no change is missed in between
+	notify, unsubscribe := u.events.subscribe(req.OrderID)
+	defer unsubscribe()
+
+	timeout := time.NewTimer(req.Wait)
+	defer timeout.Stop()
+
+	for {
+		res.GetOrderUsecaseResponse, err = u.GetOrder(ctx, req.OrderID)
+		if err != nil {
+			return dto.WaitOrderUsecaseResponse{}, err
+		}
+
+		res.Ended = processingEnded(*res.Status)
+		if res.Ended {
+			return res, nil
+		}
+
+		select {
+		case <-notify:
+		case <-timeout.C:
+			return res, nil
+		case <-ctx.Done():
+			return res, ctx.Err()
+		}
+	}
+}
+
+// GetOrderHistory returns every recorded change of an order, oldest first.
+func (u *orderUseCase) GetOrderHistory(ctx context.Context, orderID string) (res dto.GetOrderHistoryUsecaseResponse, err error) {
+	// Tell an unknown order apart from one without history
+	if _, err := u.repo.GetOrderByID(ctx, orderID); err != nil {
+		return dto.GetOrderHistoryUsecaseResponse{}, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	repoRes, err := u.repo.ListOrderEvents(ctx, dto.ListOrderEventsRepositoryRequest{OrderID: orderID})
+	if err != nil {
+		return dto.GetOrderHistoryUsecaseResponse{}, fmt.Errorf("failed to get order history: %w", err)
+	}
+
+	return dto.GetOrderHistoryUsecaseResponse{Events: repoRes.Events}, nil
+}
+
+// orderEventsBatchSize is the number of events an event stream reads at once.
+const orderEventsBatchSize = 100
+
+// StreamOrderEvents returns a channel that receives the selected order events in
+// the order they were recorded, until ctx is done.
+func (u *orderUseCase) StreamOrderEvents(ctx context.Context, req dto.StreamOrderEventsUsecaseRequest) (<-chan dto.BaseOrderEvent, error) {
+	var after uint
+	switch {
+	case req.AfterID != nil:
+		after = *req.AfterID
+	case req.OrderID == "":
+		latest, err := u.repo.LatestOrderEventID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest order event: %w", err)
+		}
+		after = latest
+	}
+
+	if req.OrderID != "" {
+		if _, err := u.repo.GetOrderByID(ctx, req.OrderID); err != nil {
+			return nil, fmt.Errorf("failed to get order: %w", err)
+		}
+	}
+
+	// Subscribe before the first read, so that no change is missed in between
+	notify, unsubscribe := u.events.subscribe(req.OrderID)
+	poll := time.NewTicker(time.Duration(max(u.config.EventStreamPollInterval, 1)) * time.Second)
+
+	events := make(chan dto.BaseOrderEvent)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+		defer poll.Stop()
+
+		for {
+			// Send everything recorded since the last event sent
+			for {
+				res, err := u.repo.ListOrderEvents(ctx, dto.ListOrderEventsRepositoryRequest{
+					OrderID: req.OrderID,
+					AfterID: after,
+					Limit:   orderEventsBatchSize,
+				})
+				if err != nil {
+					if ctx.Err() == nil {
+						logger.Error("Failed to read order events", "orderID", req.OrderID, "error", err)
+					}
+					break
+				}
+
+				for _, event := range res.Events {
+					select {
+					case events <- event:
+						after = event.ID
+					case <-ctx.Done():
+						return
+					}
+				}
+				if len(res.Events) < orderEventsBatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-notify:
+			case <-poll.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// ListOrders returns one page of the orders matching the filters. The next page is
+// requested by passing the returned NextCursor back unchanged.
+func (u *orderUseCase) ListOrders(ctx context.Context, req dto.ListOrdersUsecaseRequest) (res dto.ListOrdersUsecaseResponse, err error) {
+	listOrdersRepositoryRequest := dto.ListOrdersRepositoryRequest{
+		Statuses:      req.Statuses,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		UpdatedAfter:  req.UpdatedAfter,
+		UpdatedBefore: req.UpdatedBefore,
+		Locked:        req.Locked,
+		SortBy:        req.SortBy,
+		Descending:    req.Order == "desc",
+		Limit:         req.Limit,
+	}
+	if listOrdersRepositoryRequest.SortBy == "" {
+		listOrdersRepositoryRequest.SortBy = "created_at"
+	}
+	if listOrdersRepositoryRequest.Limit == 0 {
+		listOrdersRepositoryRequest.Limit = 50
+	}
+	for _, name := range req.Priorities {
+		priority, ok := pkg.PriorityLevel(name)
+		if !ok {
+			return dto.ListOrdersUsecaseResponse{}, fmt.Errorf("unknown priority %q", name)
+		}
+		listOrdersRepositoryRequest.Priorities = append(listOrdersRepositoryRequest.Priorities, priority)
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeOrderCursor(req.Cursor)
+		if err != nil || cursor.SortBy != listOrdersRepositoryRequest.SortBy || cursor.Descending != listOrdersRepositoryRequest.Descending {
+			return dto.ListOrdersUsecaseResponse{}, pkg.ErrInvalidCursor
+		}
+		listOrdersRepositoryRequest.After = &cursor
+	}
+
+	repoRes, err := u.repo.ListOrders(ctx, listOrdersRepositoryRequest)
+	if err != nil {
+		return dto.ListOrdersUsecaseResponse{}, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	now := time.Now()
+	res.Orders = make([]dto.GetOrderUsecaseResponse, 0, len(repoRes.Orders))
+	for _, order := range repoRes.Orders {
+		res.Orders = append(res.Orders, u.orderResponse(order, now))
+	}
+	if repoRes.Next != nil {
+		res.NextCursor = encodeOrderCursor(*repoRes.Next)
 	}
 
 	return res, nil
 }
 
-// processSingleOrder handles fetching, locking, and processing a single order.
-func (u *orderUseCase) processSingleOrder(ctx context.Context) error {
-	// Get the next high-priority ready order
-	getNextHighPriorityReadyOrder, err := u.repo.GetNextHighPriorityReadyOrder(ctx)
+// orderResponse maps a stored order to its use case response as seen at now.
+func (u *orderUseCase) orderResponse(order dto.BaseOrder, now time.Time) dto.GetOrderUsecaseResponse {
+	res := dto.GetOrderUsecaseResponse{
+		BaseOrder: order,
+	}
+	if order.Priority != nil {
+		res.PriorityName = pkg.PriorityName(*order.Priority)
+		res.EffectivePriority = *order.Priority
+
+		// Only waiting orders age
+		if order.Status != nil && canTransition(*order.Status, pkg.StatusOrderManagementRunning) && order.CreatedAt != nil {
+			res.EffectivePriority = u.priorityAging().Effective(*order.Priority, *order.CreatedAt, now)
+		}
+	}
+	return res
+}
+
+// encodeOrderCursor renders a cursor as an opaque URL-safe token.
+func encodeOrderCursor(cursor dto.OrderCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeOrderCursor parses a token produced by encodeOrderCursor.
+func decodeOrderCursor(token string) (cursor dto.OrderCursor, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
 	if err != nil {
-		logger.Error("Failed to fetch next high-priority order", "error", err)
-		return err // Handle error (e.g., log or return)
+		return cursor, err
 	}
+	err = json.Unmarshal(raw, &cursor)
+	return cursor, err
+}
 
-	// Log the order being processed
-	logger.Info("Processing order", "orderID", getNextHighPriorityReadyOrder.OrderID)
+// CancelOrder cancels an order that has not finished yet. If a worker of this
+// process is currently processing it, that processing is aborted as well.
+func (u *orderUseCase) CancelOrder(ctx context.Context, req dto.CancelOrderUsecaseRequest) error {
+	reason := req.Reason
+	if reason == "" {
+		reason = "cancelled by request"
+	}
 
-	select {
-	case u.orderCreatedSignal <- struct{}{}:
-		// Signal sent successfully, worker will process the order
-		logger.Info("Signal sent to worker to process order", "orderID", getNextHighPriorityReadyOrder.OrderID)
-	default:
-		// If the channel is full, we simply don't send the signal to avoid deadlock
-		// and continue with the execution.
-		logger.Warn("Order creation signal channel full, no signal sent", "orderID", getNextHighPriorityReadyOrder.OrderID)
+	// The order is cancelled in whichever status it is by then, as long as it may
+	// be cancelled, so that a worker claiming it or scheduling a retry meanwhile
+	// does not turn the cancellation into a conflict
+	cancelOrderRepositoryRequest := dto.CancelOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			OrderID:      &req.OrderID,
+			CancelReason: &reason,
+		},
+		Transition: dto.StatusTransition{
+			From: transitionSources(pkg.StatusOrderManagementCancelled,
+				pkg.StatusOrderManagementPending, pkg.StatusOrderManagementScheduled, pkg.StatusOrderManagementRunning),
+			To:     pkg.StatusOrderManagementCancelled,
+			Actor:  req.Actor,
+			Reason: reason,
+		},
+	}
+	err := u.repo.CancelOrder(ctx, cancelOrderRepositoryRequest)
+	if errors.Is(err, pkg.ErrStatusConflict) {
+		// Tell an order in a status it may not be cancelled from apart from one
+		// that changed status while it was being cancelled
+		order, getErr := u.repo.GetOrderByID(ctx, req.OrderID)
+		if getErr != nil {
+			return getErr
+		}
+		if transitionErr := validateTransition(req.OrderID, *order.Status, pkg.StatusOrderManagementCancelled); transitionErr != nil {
+			return transitionErr
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to cancel order", "orderID", req.OrderID, "error", err)
+		return err
+	}
+	u.events.publish(req.OrderID)
+
+	u.mu.Lock()
+	abort, running := u.inFlight[req.OrderID]
+	u.mu.Unlock()
+	if running {
+		abort(errOrderCancelled)
+	}
+
+	logger.Info("Order cancelled", "orderID", req.OrderID, "reason", reason, "aborted", running)
+	return nil
+}
+
+// ListDeadLetters returns the dead-lettered orders with their attempt history.
+func (u *orderUseCase) ListDeadLetters(ctx context.Context, req dto.ListDeadLettersUsecaseRequest) (res dto.ListDeadLettersUsecaseResponse, err error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = 50
 	}
 
-	// Lock the order optimistically
-	lockOrderOptimisticRepositoryRequest := dto.LockOrderOptimisticRepositoryRequest{
-		BaseOrder: getNextHighPriorityReadyOrder.BaseOrder,
+	repoRes, err := u.repo.ListDeadLetters(ctx, dto.ListDeadLettersRepositoryRequest{Limit: limit, Offset: req.Offset})
+	if err != nil {
+		return dto.ListDeadLettersUsecaseResponse{}, fmt.Errorf("failed to list dead letters: %w", err)
 	}
-	if err := u.repo.LockOrderOptimistic(ctx, lockOrderOptimisticRepositoryRequest); err != nil {
-		logger.Error("Failed to lock order optimistically", "orderID", getNextHighPriorityReadyOrder.OrderID, "error", err)
-		return err // Handle error (e.g., log or return)
+
+	return dto.ListDeadLettersUsecaseResponse{DeadLetters: repoRes.DeadLetters}, nil
+}
+
+// ReplayDeadLetter returns a single dead-lettered order to the queue.
+func (u *orderUseCase) ReplayDeadLetter(ctx context.Context, req dto.ReplayDeadLetterUsecaseRequest) error {
+	orderID := req.OrderID
+	res, err := u.ReplayDeadLetters(ctx, dto.ReplayDeadLettersUsecaseRequest{OrderIDs: []string{orderID}, Actor: req.Actor})
+	if err != nil {
+		return err
 	}
 
+	if len(res.OrderIDs) == 0 {
+		// Tell an unknown order apart from one that is not dead-lettered
+		order, err := u.repo.GetOrderByID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		return &pkg.StateTransitionError{OrderID: orderID, From: *order.Status, To: pkg.StatusOrderManagementPending}
+	}
+
+	return nil
+}
+
+// ReplayDeadLetters returns the selected dead-lettered orders, or all of them with
+// req.All, to the queue with a fresh attempt budget. Replaying every dead letter
+// has to be asked for explicitly, so an empty selection is rejected.
+func (u *orderUseCase) ReplayDeadLetters(ctx context.Context, req dto.ReplayDeadLettersUsecaseRequest) (res dto.ReplayDeadLettersUsecaseResponse, err error) {
+	switch {
+	case req.All && len(req.OrderIDs) > 0:
+		return dto.ReplayDeadLettersUsecaseResponse{}, pkg.ErrInvalidRequest.WithDescription("order_ids cannot be combined with all")
+	case !req.All && len(req.OrderIDs) == 0:
+		return dto.ReplayDeadLettersUsecaseResponse{}, pkg.ErrInvalidRequest.WithDescription("order_ids must list at least one order, or all must be true")
+	}
+
+	repoRes, err := u.repo.ReplayDeadLetters(ctx, dto.ReplayDeadLettersRepositoryRequest{
+		OrderIDs: req.OrderIDs,
+		All:      req.All,
+		Transition: dto.StatusTransition{
+			From:   transitionSources(pkg.StatusOrderManagementPending, pkg.StatusOrderManagementDeadLettered),
+			To:     pkg.StatusOrderManagementPending,
+			Actor:  req.Actor,
+			Reason: "replayed from dead letters",
+		},
+	})
+	if err != nil {
+		logger.Error("Failed to replay dead letters", "error", err)
+		return dto.ReplayDeadLettersUsecaseResponse{}, err
+	}
+
+	logger.Info("Dead letters replayed", "count", len(repoRes.OrderIDs))
+	if len(repoRes.OrderIDs) > 0 {
+		u.events.publish(repoRes.OrderIDs...)
+		u.wakeAllWorkers(len(repoRes.OrderIDs))
+	}
+
+	return dto.ReplayDeadLettersUsecaseResponse{OrderIDs: repoRes.OrderIDs}, nil
+}
+
+// trackInFlight registers the abort function of an order being processed and
+// returns a function that removes it again.
+func (u *orderUseCase) trackInFlight(orderID string, abort context.CancelCauseFunc) (untrack func()) {
+	u.mu.Lock()
+	u.inFlight[orderID] = abort
+	u.mu.Unlock()
+
+	return func() {
+		u.mu.Lock()
+		delete(u.inFlight, orderID)
+		u.mu.Unlock()
+	}
+}
+
+// processSingleOrder handles claiming and processing a single order. It reports
+// whether an order was claimable.
+func (u *orderUseCase) processSingleOrder(ctx context.Context, w *worker) (claimed bool, err error) {
+	owner := w.owner
+
+	// Claim the next high-priority ready order by taking a lease on it
+	acquiredAt := time.Now()
+	expiresAt := acquiredAt.Add(u.leaseDuration())
+	claimNextOrderRepositoryRequest := dto.ClaimNextOrderRepositoryRequest{
+		BaseOrder: dto.BaseOrder{
+			LockOwner:      &owner,
+			LockAcquiredAt: &acquiredAt,
+			LockExpiresAt:  &expiresAt,
+		},
+		Transition: dto.StatusTransition{
+			From:   transitionSources(pkg.StatusOrderManagementRunning, pkg.StatusOrderManagementPending, pkg.StatusOrderManagementScheduled),
+			To:     pkg.StatusOrderManagementRunning,
+			Actor:  owner,
+			Reason: "claimed for processing",
+		},
+		Aging:       u.priorityAging(),
+		Now:         acquiredAt,
+		MinPriority: w.minPriority,
+	}
+	u.stats.claims.Add(1)
+	claimedOrder, err := u.repo.ClaimNextOrder(ctx, claimNextOrderRepositoryRequest)
+	if errors.Is(err, pkg.ErrNotFound) {
+		return false, nil
+	}
+	if errors.Is(err, pkg.ErrUnavailable) {
+		u.stats.contended.Add(1)
+	}
+	if err != nil {
+		logger.Error("Failed to claim next high-priority order", "error", err)
+		return false, err
+	}
+
+	// Log the order being processed
+	logger.Info("Processing order", "orderID", claimedOrder.OrderID, "owner", owner)
+	w.busyWith(*claimedOrder.OrderID)
+	defer w.idle()
+	u.events.publish(*claimedOrder.OrderID)
+
+	// Keep the lease alive for as long as the order is being processed, and let
+	// CancelOrder abort the processing
+	processCtx, cancel := context.WithCancelCause(ctx)
+	untrack := u.trackInFlight(*claimedOrder.OrderID, cancel)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		u.renewLock(processCtx, cancel, claimedOrder.OrderID, owner)
+	}()
+
 	// Process the order using the process service
-	status, err := u.process.ProcessOrder(ctx, *getNextHighPriorityReadyOrder.ProcessingTime)
+	status, err := u.process.ProcessOrder(processCtx, *claimedOrder.ProcessingTime)
+	u.stats.recordProcessed(time.Since(acquiredAt))
+	untrack()
+	aborted := context.Cause(processCtx)
+	cancel(nil)
+	<-renewed
 	if err != nil {
 		// Handle process failure
-		logger.Error("Failed to process order", "orderID", getNextHighPriorityReadyOrder.OrderID, "error", err)
-		return err
+		logger.Error("Failed to process order", "orderID", claimedOrder.OrderID, "error", err)
+		return true, err
+	}
+
+	switch {
+	case errors.Is(aborted, errOrderCancelled):
+		// CancelOrder has already moved the order to Cancelled and dropped its lease
+		logger.Info("Order processing cancelled", "orderID", claimedOrder.OrderID)
+		return true, nil
+	case errors.Is(aborted, errOrderLockLost):
+		// The lease was released; the order is back in the queue for another worker
+		logger.Warn("Order processing abandoned after losing its lock", "orderID", claimedOrder.OrderID)
+		return true, nil
+	case errors.Is(aborted, errShuttingDown):
+		// ProcessOrder returns the order to the queue once every worker stopped
+		logger.Warn("Order processing interrupted by shutdown", "orderID", claimedOrder.OrderID)
+		return true, nil
 	}
 
 	// Log order status after processing
-	logger.Info("Order processed successfully", "orderID", getNextHighPriorityReadyOrder.OrderID, "status", status)
+	logger.Info("Order processed successfully", "orderID", claimedOrder.OrderID, "status", status)
 
-	// Update the order status based on the processing result
-	updateRequest := dto.UpdateOrderByIDRepositoryRequest{
+	// Store the processing result and release the lease
+	attempts := *claimedOrder.Attempts
+	finishedAt := time.Now()
+	completeRequest := dto.CompleteOrderRepositoryRequest{
 		BaseOrder: dto.BaseOrder{
-			OrderID: getNextHighPriorityReadyOrder.OrderID,
-			Status:  &status,
-			Lock:    new(bool),
+			OrderID:   claimedOrder.OrderID,
+			LockOwner: &owner,
+		},
+		Transition: dto.StatusTransition{
+			From:  []string{pkg.StatusOrderManagementRunning},
+			To:    status,
+			Actor: owner,
+		},
+		Attempt: dto.BaseOrderAttempt{
+			OrderID:    claimedOrder.OrderID,
+			Attempt:    &attempts,
+			LockOwner:  &owner,
+			Status:     &status,
+			StartedAt:  claimedOrder.LockAcquiredAt,
+			FinishedAt: &finishedAt,
 		},
 	}
-	if err := u.repo.UpdateOrderByID(ctx, updateRequest); err != nil {
-		logger.Error("Failed to update order status", "orderID", getNextHighPriorityReadyOrder.OrderID, "error", err)
-		return err // Handle error (e.g., log or return)
+
+	// Failed attempts are retried later until the attempt budget is spent, after
+	// which the order is dead-lettered. Idle workers poll for retries that are due.
+	if status == pkg.StatusOrderManagementFailed {
+		lastError := fmt.Sprintf("processing failed on attempt %d", attempts)
+		completeRequest.LastError = &lastError
+		completeRequest.Attempt.Error = &lastError
+		completeRequest.Transition.Reason = lastError
+
+		switch {
+		case attempts < u.config.MaxAttempts:
+			backoff := u.retryBackoff(attempts)
+			nextAttemptAt := finishedAt.Add(backoff)
+			completeRequest.Transition.To = pkg.StatusOrderManagementScheduled
+			completeRequest.NextAttemptAt = &nextAttemptAt
+			completeRequest.Transition.Reason = fmt.Sprintf("%s; retrying in %s", lastError, backoff)
+			logger.Warn("Order attempt failed, scheduling retry", "orderID", claimedOrder.OrderID, "attempt", attempts, "maxAttempts", u.config.MaxAttempts, "backoff", backoff)
+		case u.config.DeadLetterEnabled:
+			completeRequest.Transition.To = pkg.StatusOrderManagementDeadLettered
+			completeRequest.DeadLetteredAt = &finishedAt
+			logger.Error("Order failed, no attempts left, moved to dead letters", "orderID", claimedOrder.OrderID, "attempts", attempts)
+		default:
+			logger.Error("Order failed, no attempts left", "orderID", claimedOrder.OrderID, "attempts", attempts)
+		}
 	}
 
-	return nil
+	if err := validateTransition(*claimedOrder.OrderID, pkg.StatusOrderManagementRunning, completeRequest.Transition.To); err != nil {
+		logger.Error("Refusing order status change", "orderID", claimedOrder.OrderID, "error", err)
+		return true, err
+	}
+
+	if err := u.repo.CompleteOrder(ctx, completeRequest); err != nil {
+		if errors.Is(err, pkg.ErrLockNotHeld) {
+			// CancelOrder dropped the lease after the processing ended but before
+			// it could abort it, or the lease expired; the order has moved on
+			logger.Info("Order lock no longer held, processing result dropped", "orderID", claimedOrder.OrderID, "status", status)
+			return true, nil
+		}
+		logger.Error("Failed to update order status", "orderID", claimedOrder.OrderID, "error", err)
+		return true, err // Handle error (e.g., log or return)
+	}
+	u.events.publish(*claimedOrder.OrderID)
+
+	return true, nil
+}
+
+// retryBackoff returns how long to wait before retrying an order that failed its
+// given attempt: the base backoff doubled per previous attempt, capped at the maximum.
+func (u *orderUseCase) retryBackoff(attempt int) time.Duration {
+	return exponentialBackoff(
+		time.Duration(u.config.RetryBackoffBase)*time.Second,
+		time.Duration(u.config.RetryBackoffMax)*time.Second,
+		attempt,
+	)
+}
+
+// exponentialBackoff returns base doubled per attempt after the first, capped at
+// maxBackoff.
+func exponentialBackoff(base, maxBackoff time.Duration, attempt int) time.Duration {
+	backoff := base
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
 }
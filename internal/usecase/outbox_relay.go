@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+)
+
+const (
+	// outboxBatchSize is the number of messages a relay publishes at once.
+	outboxBatchSize = 100
+
+	// outboxClaimDuration is how long a claimed message is hidden from other relays.
+	outboxClaimDuration = time.Minute
+
+	// outboxCleanupInterval is how often published messages past their retention
+	// are deleted.
+	outboxCleanupInterval = 10 * time.Minute
+
+	// Publishing a message that failed is retried after outboxBackoffBase, doubled
+	// per attempt, up to outboxBackoffMax.
+	outboxBackoffBase = time.Second
+	outboxBackoffMax  = time.Minute
+)
+
+// outboxRelay is the concrete implementation of the OutboxRelay interface. It
+// publishes every outbox message to every sink at least once: a message is only
+// marked published once all sinks took it, and is published to all of them again
+// otherwise.
+type outboxRelay struct {
+	config config.App
+	repo   interfaces.OutboxRepository
+	sinks  []interfaces.OutboxSink
+}
+
+// NewOutboxRelay creates a new instance of outboxRelay.
+func NewOutboxRelay(config config.App, repo interfaces.OutboxRepository, sinks ...interfaces.OutboxSink) *outboxRelay {
+	return &outboxRelay{config: config, repo: repo, sinks: sinks}
+}
+
+// RelayOutbox publishes due outbox messages and deletes old published ones until
+// the context is canceled.
+func (r *outboxRelay) RelayOutbox(ctx context.Context) error {
+	poll := time.NewTicker(time.Duration(max(r.config.OutboxPollInterval, 1)) * time.Second)
+	defer poll.Stop()
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	logger.Info("Starting outbox relay", "sinks", len(r.sinks))
+	for {
+		// After a full batch more messages may be due already
+		if r.relayBatch(ctx) == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-poll.C:
+		case <-cleanup.C:
+			r.deletePublished(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// relayBatch claims a batch of due messages, publishes them in order and returns
+// how many were claimed.
+func (r *outboxRelay) relayBatch(ctx context.Context) int {
+	now := time.Now()
+	res, err := r.repo.ClaimOutboxMessages(ctx, dto.ClaimOutboxMessagesRepositoryRequest{
+		Now:   now,
+		Until: now.Add(outboxClaimDuration),
+		Limit: outboxBatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to claim outbox messages", "error", err)
+		}
+		return 0
+	}
+
+	for _, message := range res.Messages {
+		r.relay(ctx, message)
+	}
+	return len(res.Messages)
+}
+
+// relay publishes one message to every sink and stores the outcome.
+func (r *outboxRelay) relay(ctx context.Context, message dto.BaseOutboxMessage) {
+	attempts := *message.Attempts + 1
+	result := dto.BaseOutboxMessage{
+		ID:       message.ID,
+		Attempts: &attempts,
+	}
+
+	finishedAt := time.Now()
+	if err := r.publish(ctx, message); err != nil {
+		backoff := exponentialBackoff(outboxBackoffBase, outboxBackoffMax, attempts)
+		nextAttemptAt := finishedAt.Add(backoff)
+		lastError := err.Error()
+		result.NextAttemptAt = &nextAttemptAt
+		result.LastError = &lastError
+		logger.Warn("Failed to publish outbox message, scheduling retry", "messageID", message.ID, "attempt", attempts, "backoff", backoff, "error", err)
+	} else {
+		result.PublishedAt = &finishedAt
+	}
+
+	// Store the outcome even if the relay is stopping meanwhile
+	err := r.repo.CompleteOutboxMessage(context.WithoutCancel(ctx), dto.CompleteOutboxMessageRepositoryRequest{BaseOutboxMessage: result})
+	if err != nil {
+		logger.Error("Failed to store outbox message outcome", "messageID", message.ID, "error", err)
+	}
+}
+
+// publish hands a message to every sink, stopping at the first that fails.
+func (r *outboxRelay) publish(ctx context.Context, message dto.BaseOutboxMessage) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, message); err != nil {
+			return fmt.Errorf("%s sink: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// deletePublished deletes the messages published longer ago than the retention.
+func (r *outboxRelay) deletePublished(ctx context.Context) {
+	if r.config.OutboxRetention <= 0 {
+		return
+	}
+
+	deleted, err := r.repo.DeletePublishedOutboxMessages(ctx, dto.DeletePublishedOutboxMessagesRepositoryRequest{
+		PublishedBefore: time.Now().Add(-time.Duration(r.config.OutboxRetention) * time.Hour),
+	})
+	if err != nil {
+		logger.Error("Failed to delete published outbox messages", "error", err)
+		return
+	}
+	if deleted > 0 {
+		logger.Info("Deleted published outbox messages", "deleted", deleted)
+	}
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// orderTransitions is the order lifecycle: the statuses each status may move to.
+// Every status change requested from the repository is checked against it.
+//
+//	Pending      -> Running (claimed), Cancelled
+//	Scheduled    -> Running (retry is due), Cancelled
+//	Running      -> Processed, Scheduled (retry), Failed, DeadLettered,
+//	                Cancelled, Pending (lease released)
+//	DeadLettered -> Pending (replayed)
+var orderTransitions = map[string][]string{
+	pkg.StatusOrderManagementPending: {
+		pkg.StatusOrderManagementRunning,
+		pkg.StatusOrderManagementCancelled,
+	},
+	pkg.StatusOrderManagementScheduled: {
+		pkg.StatusOrderManagementRunning,
+		pkg.StatusOrderManagementCancelled,
+	},
+	pkg.StatusOrderManagementRunning: {
+		pkg.StatusOrderManagementProcessed,
+		pkg.StatusOrderManagementScheduled,
+		pkg.StatusOrderManagementFailed,
+		pkg.StatusOrderManagementDeadLettered,
+		pkg.StatusOrderManagementCancelled,
+		pkg.StatusOrderManagementPending,
+	},
+	pkg.StatusOrderManagementDeadLettered: {
+		pkg.StatusOrderManagementPending,
+	},
+	pkg.StatusOrderManagementProcessed: {},
+	pkg.StatusOrderManagementFailed:    {},
+	pkg.StatusOrderManagementCancelled: {},
+}
+
+// canTransition reports whether an order may move from one status to another.
+func canTransition(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// processingEnded reports whether an order in the given status will not be
+// processed again on its own: it is in a terminal status, or dead-lettered and
+// waiting for an operator to replay it.
+func processingEnded(status string) bool {
+	return len(orderTransitions[status]) == 0 || status == pkg.StatusOrderManagementDeadLettered
+}
+
+// validateTransition returns a *pkg.StateTransitionError if the order may not
+// move from one status to another.
+func validateTransition(orderID, from, to string) error {
+	if !canTransition(from, to) {
+		return &pkg.StateTransitionError{OrderID: orderID, From: from, To: to}
+	}
+	return nil
+}
+
+// transitionSources returns the statuses among candidates that may move to the
+// given status, for repository updates that select orders by status.
+func transitionSources(to string, candidates ...string) []string {
+	sources := make([]string, 0, len(candidates))
+	for _, from := range candidates {
+		if canTransition(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// releaseTransition returns an order whose lease was lost to the queue.
+func releaseTransition(actor, reason string) dto.StatusTransition {
+	return dto.StatusTransition{
+		From:   transitionSources(pkg.StatusOrderManagementPending, pkg.StatusOrderManagementRunning),
+		To:     pkg.StatusOrderManagementPending,
+		Actor:  actor,
+		Reason: reason,
+	}
+}
@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seyedmo30/order_management/internal/config"
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/internal/interfaces"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// errWebhookDeleted fails a delivery whose webhook was deleted after it was claimed.
+var errWebhookDeleted = errors.New("webhook deleted")
+
+// webhookDeliveryBatchSize is the number of deliveries a dispatcher sends at once.
+const webhookDeliveryBatchSize = 20
+
+// webhookUseCase is the concrete implementation of the WebhookUseCase interface.
+type webhookUseCase struct {
+	config config.App
+	repo   interfaces.WebhookRepository
+	client *http.Client
+}
+
+// NewWebhookUseCase creates a new instance of webhookUseCase.
+func NewWebhookUseCase(config config.App, repo interfaces.WebhookRepository) *webhookUseCase {
+	return &webhookUseCase{
+		config: config,
+		repo:   repo,
+		client: &http.Client{Timeout: time.Duration(config.WebhookTimeout) * time.Second},
+	}
+}
+
+// webhookPayload is the JSON body of a webhook request.
+type webhookPayload struct {
+	EventID    uint       `json:"event_id"`
+	OrderID    *string    `json:"order_id"`
+	FromStatus *string    `json:"from_status"`
+	ToStatus   *string    `json:"to_status"`
+	Actor      *string    `json:"actor"`
+	Reason     *string    `json:"reason"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// CreateWebhook subscribes an endpoint to order events.
+func (u *webhookUseCase) CreateWebhook(ctx context.Context, req dto.CreateWebhookUsecaseRequest) (res dto.CreateWebhookUsecaseResponse, err error) {
+	secret := req.Secret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return dto.CreateWebhookUsecaseResponse{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(raw)
+	}
+
+	statuses := strings.Join(req.Statuses, ",")
+	subscription := dto.BaseWebhookSubscription{
+		ID:       uuid.NewString(),
+		URL:      &req.URL,
+		Secret:   &secret,
+		Statuses: &statuses,
+	}
+	if err := u.repo.CreateWebhookSubscription(ctx, dto.CreateWebhookSubscriptionRepositoryRequest{BaseWebhookSubscription: subscription}); err != nil {
+		logger.Error("Failed to create webhook", "url", req.URL, "error", err)
+		return dto.CreateWebhookUsecaseResponse{}, err
+	}
+
+	logger.Info("Webhook created", "webhookID", subscription.ID, "url", req.URL)
+	return dto.CreateWebhookUsecaseResponse{ID: subscription.ID, Secret: secret}, nil
+}
+
+func (u *webhookUseCase) ListWebhooks(ctx context.Context) (res dto.ListWebhooksUsecaseResponse, err error) {
+	repoRes, err := u.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return dto.ListWebhooksUsecaseResponse{}, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return dto.ListWebhooksUsecaseResponse{Webhooks: repoRes.Subscriptions}, nil
+}
+
+func (u *webhookUseCase) DeleteWebhook(ctx context.Context, webhookID string) error {
+	if err := u.repo.DeleteWebhookSubscription(ctx, webhookID); err != nil {
+		logger.Error("Failed to delete webhook", "webhookID", webhookID, "error", err)
+		return err
+	}
+
+	logger.Info("Webhook deleted", "webhookID", webhookID)
+	return nil
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook, newest first.
+func (u *webhookUseCase) ListWebhookDeliveries(ctx context.Context, req dto.ListWebhookDeliveriesUsecaseRequest) (res dto.ListWebhookDeliveriesUsecaseResponse, err error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = 50
+	}
+
+	repoRes, err := u.repo.ListWebhookDeliveries(ctx, dto.ListWebhookDeliveriesRepositoryRequest{
+		SubscriptionID: req.WebhookID,
+		Limit:          limit,
+		Offset:         req.Offset,
+	})
+	if err != nil {
+		return dto.ListWebhookDeliveriesUsecaseResponse{}, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return dto.ListWebhookDeliveriesUsecaseResponse{Deliveries: repoRes.Deliveries}, nil
+}
+
+// DispatchWebhooks sends due webhook deliveries until the context is canceled.
+func (u *webhookUseCase) DispatchWebhooks(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(max(u.config.WebhookPollInterval, 1)) * time.Second)
+	defer ticker.Stop()
+
+	logger.Info("Starting webhook dispatcher")
+	for {
+		// After a full batch more deliveries may be due already
+		if u.dispatchBatch(ctx) == webhookDeliveryBatchSize {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// dispatchBatch claims a batch of due deliveries, sends them concurrently and
+// returns how many were claimed.
+func (u *webhookUseCase) dispatchBatch(ctx context.Context) int {
+	now := time.Now()
+	res, err := u.repo.ClaimWebhookDeliveries(ctx, dto.ClaimWebhookDeliveriesRepositoryRequest{
+		Now: now,
+		// A delivery left behind by a crashed dispatcher is sent again once the
+		// attempt has certainly timed out
+		Until: now.Add(2 * u.client.Timeout),
+		Limit: webhookDeliveryBatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to claim webhook deliveries", "error", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range res.Deliveries {
+		wg.Add(1)
+		go func(delivery dto.WebhookDeliveryRepositoryResponse) {
+			defer wg.Done()
+			u.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(res.Deliveries)
+}
+
+// deliver sends one delivery and stores its outcome, scheduling a retry when it
+// failed and attempts are left.
+func (u *webhookUseCase) deliver(ctx context.Context, delivery dto.WebhookDeliveryRepositoryResponse) {
+	attempts := *delivery.Attempts + 1
+	result := dto.BaseWebhookDelivery{
+		ID:       delivery.ID,
+		Status:   &pkg.StatusWebhookDeliveryDelivered,
+		Attempts: &attempts,
+	}
+
+	responseCode, err := u.send(ctx, delivery)
+	if responseCode != 0 {
+		result.ResponseCode = &responseCode
+	}
+
+	finishedAt := time.Now()
+	if err == nil {
+		result.DeliveredAt = &finishedAt
+		logger.Info("Webhook delivered", "deliveryID", delivery.ID, "webhookID", delivery.SubscriptionID, "orderID", delivery.OrderID)
+	} else {
+		lastError := err.Error()
+		result.LastError = &lastError
+
+		if attempts < u.config.WebhookMaxAttempts && !errors.Is(err, errWebhookDeleted) {
+			backoff := exponentialBackoff(
+				time.Duration(u.config.WebhookBackoffBase)*time.Second,
+				time.Duration(u.config.WebhookBackoffMax)*time.Second,
+				attempts,
+			)
+			nextAttemptAt := finishedAt.Add(backoff)
+			result.Status = &pkg.StatusWebhookDeliveryPending
+			result.NextAttemptAt = &nextAttemptAt
+			logger.Warn("Webhook delivery failed, scheduling retry", "deliveryID", delivery.ID, "attempt", attempts, "backoff", backoff, "error", err)
+		} else {
+			result.Status = &pkg.StatusWebhookDeliveryFailed
+			logger.Error("Webhook delivery failed, no attempts left", "deliveryID", delivery.ID, "attempts", attempts, "error", err)
+		}
+	}
+
+	// Store the outcome even if the dispatcher is stopping meanwhile
+	err = u.repo.CompleteWebhookDelivery(context.WithoutCancel(ctx), dto.CompleteWebhookDeliveryRepositoryRequest{BaseWebhookDelivery: result})
+	if err != nil {
+		logger.Error("Failed to store webhook delivery outcome", "deliveryID", delivery.ID, "error", err)
+	}
+}
+
+// send posts the signed event of a delivery to its subscription. Any response
+// other than 2xx is an error.
+func (u *webhookUseCase) send(ctx context.Context, delivery dto.WebhookDeliveryRepositoryResponse) (responseCode int, err error) {
+	if delivery.Subscription.URL == nil {
+		return 0, errWebhookDeleted
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		EventID:    delivery.Event.ID,
+		OrderID:    delivery.Event.OrderID,
+		FromStatus: delivery.Event.FromStatus,
+		ToStatus:   delivery.Event.ToStatus,
+		Actor:      delivery.Event.Actor,
+		Reason:     delivery.Event.Reason,
+		OccurredAt: delivery.Event.CreatedAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *delivery.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(pkg.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(pkg.WebhookSignatureHeader, "sha256="+pkg.SignWebhookPayload(*delivery.Subscription.Secret, timestamp, body))
+	req.Header.Set(pkg.WebhookEventIDHeader, strconv.FormatUint(uint64(delivery.Event.ID), 10))
+	req.Header.Set(pkg.WebhookDeliveryIDHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,402 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seyedmo30/order_management/internal/dto"
+	"github.com/seyedmo30/order_management/pkg"
+)
+
+// maxWorkers bounds the size of the worker pool.
+const maxWorkers = 256
+
+// worker is a member of the worker pool. It is retired by closing retire, after
+// which it stops once its current order is done. A worker reserved for a priority
+// only claims orders of level minPriority or above.
+type worker struct {
+	id          int
+	owner       string
+	reservedFor string
+	minPriority int
+	retire      chan struct{}
+
+	mu       sync.Mutex
+	retiring bool
+	orderID  *string
+	since    time.Time
+}
+
+func (w *worker) busyWith(orderID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.orderID = &orderID
+	w.since = time.Now()
+}
+
+func (w *worker) idle() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.orderID = nil
+	w.since = time.Now()
+}
+
+// retired reports whether the worker was retired.
+func (w *worker) retired() bool {
+	select {
+	case <-w.retire:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *worker) status() dto.WorkerUsecaseResponse {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res := dto.WorkerUsecaseResponse{ID: w.id, Owner: w.owner, ReservedFor: w.reservedFor, OrderID: w.orderID, Since: w.since}
+	switch {
+	case w.retiring:
+		res.State = pkg.StatusWorkerRetiring
+	case w.orderID != nil:
+		res.State = pkg.StatusWorkerBusy
+	default:
+		res.State = pkg.StatusWorkerIdle
+	}
+	return res
+}
+
+// workerStats counts what the workers did since the process started.
+type workerStats struct {
+	claims         atomic.Int64
+	contended      atomic.Int64
+	processed      atomic.Int64
+	processingTime atomic.Int64 // nanoseconds
+}
+
+func (s *workerStats) recordProcessed(duration time.Duration) {
+	s.processed.Add(1)
+	s.processingTime.Add(int64(duration))
+}
+
+func (s *workerStats) snapshot() dto.WorkerStats {
+	return dto.WorkerStats{
+		Claims:         s.claims.Load(),
+		Contended:      s.contended.Load(),
+		Processed:      s.processed.Load(),
+		ProcessingTime: time.Duration(s.processingTime.Load()),
+	}
+}
+
+// workerReservation is a number of workers reserved for a priority and above.
+type workerReservation struct {
+	priority string
+	level    int
+	workers  int
+}
+
+// workerPool keeps size shared workers running while ProcessOrder runs, starting
+// new workers when it grows and retiring the newest ones when it shrinks. The
+// reserved workers run besides them and are not resized.
+type workerPool struct {
+	mu           sync.Mutex
+	size         int
+	reservations []workerReservation
+	workers      map[int]*worker
+	nextID       int
+	lockOwner    func(workerID int) string
+	start        func(*worker) // Set while ProcessOrder runs
+	resizedAt    *time.Time
+	resizedBy    string
+	resizeReason string
+}
+
+func newWorkerPool(size int, reservations map[string]int, lockOwner func(workerID int) string) *workerPool {
+	if size > maxWorkers {
+		logger.Warn("Worker count exceeds the maximum, capping it", "workerCount", size, "maxWorkers", maxWorkers)
+		size = maxWorkers
+	}
+
+	p := &workerPool{size: max(size, 0), workers: make(map[int]*worker), lockOwner: lockOwner}
+	for priority, workers := range reservations {
+		level, ok := pkg.PriorityLevel(priority)
+		if !ok || workers <= 0 {
+			continue
+		}
+		p.reservations = append(p.reservations, workerReservation{priority: priority, level: level, workers: workers})
+	}
+	sort.Slice(p.reservations, func(i, j int) bool { return p.reservations[i].level > p.reservations[j].level })
+	return p
+}
+
+// run starts the reserved workers and the shared ones with start, and any worker
+// the pool grows by until stop.
+func (p *workerPool) run(start func(*worker)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = start
+
+	for _, reservation := range p.reservations {
+		for i := 0; i < reservation.workers; i++ {
+			p.add(reservation.priority, reservation.level)
+		}
+	}
+	p.scale()
+}
+
+// add starts a worker. The caller holds p.mu.
+func (p *workerPool) add(reservedFor string, minPriority int) {
+	w := &worker{
+		id:          p.nextID,
+		owner:       p.lockOwner(p.nextID),
+		reservedFor: reservedFor,
+		minPriority: minPriority,
+		retire:      make(chan struct{}),
+		since:       time.Now(),
+	}
+	p.nextID++
+	p.workers[w.id] = w
+	p.start(w)
+}
+
+// reserved returns the number of reserved workers.
+func (p *workerPool) reserved() (workers int) {
+	for _, reservation := range p.reservations {
+		workers += reservation.workers
+	}
+	return workers
+}
+
+// stop stops starting workers; the running ones stop on their own.
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = nil
+}
+
+// resize sets the size of the pool and returns the previous one.
+func (p *workerPool) resize(size int, actor, reason string) (previous int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	previous = p.size
+	p.size = size
+	p.resizedAt = &now
+	p.resizedBy = actor
+	p.resizeReason = reason
+	p.scale()
+	return previous
+}
+
+func (p *workerPool) currentSize() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// remove forgets a worker that stopped.
+func (p *workerPool) remove(w *worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.workers, w.id)
+}
+
+// scale starts or retires shared workers until size of them are not retiring.
+// The caller holds p.mu.
+func (p *workerPool) scale() {
+	if p.start == nil {
+		return
+	}
+
+	active := make([]*worker, 0, len(p.workers))
+	for _, w := range p.workers {
+		if w.minPriority == 0 && !w.retired() {
+			active = append(active, w)
+		}
+	}
+
+	for i := len(active); i < p.size; i++ {
+		p.add("", 0)
+	}
+
+	// Retire the newest workers first
+	sort.Slice(active, func(i, j int) bool { return active[i].id > active[j].id })
+	for _, w := range active[:max(len(active)-p.size, 0)] {
+		w.mu.Lock()
+		w.retiring = true
+		w.mu.Unlock()
+		close(w.retire)
+	}
+}
+
+// statuses returns the state of every worker, ordered by ID.
+func (p *workerPool) statuses() []dto.WorkerUsecaseResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := make([]dto.WorkerUsecaseResponse, 0, len(p.workers))
+	for _, w := range p.workers {
+		res = append(res, w.status())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// workerGate lets workers claim orders unless the pool is paused, and counts the
+// workers that are busy between claiming an order and finishing it.
+type workerGate struct {
+	mu        sync.Mutex
+	paused    bool
+	busy      int
+	idle      chan struct{} // Closed while no worker is busy
+	changedAt *time.Time
+	changedBy string
+}
+
+func newWorkerGate() *workerGate {
+	idle := make(chan struct{})
+	close(idle)
+	return &workerGate{idle: idle}
+}
+
+// enter reports whether a worker may claim an order. A worker that entered is
+// busy until it leaves.
+func (g *workerGate) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		return false
+	}
+	if g.busy == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.busy++
+	return true
+}
+
+func (g *workerGate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.busy--
+	if g.busy == 0 {
+		close(g.idle)
+	}
+}
+
+// setPaused pauses or resumes the pool and reports whether that changed it.
+func (g *workerGate) setPaused(paused bool, actor string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused == paused {
+		return false
+	}
+	now := time.Now()
+	g.paused = paused
+	g.changedAt = &now
+	g.changedBy = actor
+	return true
+}
+
+// idled returns a channel that is closed once no worker is busy.
+func (g *workerGate) idled() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.idle
+}
+
+// GetWorkerPool returns the state of the worker pool of this process: running,
+// draining while paused with orders still in flight, or paused.
+func (u *orderUseCase) GetWorkerPool(ctx context.Context) (res dto.WorkerPoolUsecaseResponse, err error) {
+	res.Workers = u.pool.statuses()
+	res.Stats = u.stats.snapshot()
+
+	u.pool.mu.Lock()
+	res.Size = u.pool.size
+	res.ResizedAt = u.pool.resizedAt
+	res.ResizedBy = u.pool.resizedBy
+	res.ResizeReason = u.pool.resizeReason
+	u.pool.mu.Unlock()
+
+	u.gate.mu.Lock()
+	res.Busy = u.gate.busy
+	res.ChangedAt = u.gate.changedAt
+	res.ChangedBy = u.gate.changedBy
+	switch {
+	case !u.gate.paused:
+		res.State = pkg.StatusWorkerPoolRunning
+	case u.gate.busy > 0:
+		res.State = pkg.StatusWorkerPoolDraining
+	default:
+		res.State = pkg.StatusWorkerPoolPaused
+	}
+	u.gate.mu.Unlock()
+
+	u.mu.Lock()
+	res.InFlight = make([]string, 0, len(u.inFlight))
+	for orderID := range u.inFlight {
+		res.InFlight = append(res.InFlight, orderID)
+	}
+	u.mu.Unlock()
+	sort.Strings(res.InFlight)
+
+	return res, nil
+}
+
+// PauseWorkers stops the workers from claiming orders, while the orders they are
+// processing finish and new orders are still accepted.
+func (u *orderUseCase) PauseWorkers(ctx context.Context, req dto.ControlWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error) {
+	if u.gate.setPaused(true, req.Actor) {
+		logger.Warn("Worker pool paused", "actor", req.Actor)
+	}
+	return u.GetWorkerPool(ctx)
+}
+
+// ResumeWorkers lets the workers claim orders again and wakes them.
+func (u *orderUseCase) ResumeWorkers(ctx context.Context, req dto.ControlWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error) {
+	if u.gate.setPaused(false, req.Actor) {
+		logger.Info("Worker pool resumed", "actor", req.Actor)
+		u.wakeAllWorkers(u.pool.currentSize() + u.pool.reserved())
+	}
+	return u.GetWorkerPool(ctx)
+}
+
+// DrainWorkers pauses the workers and waits up to req.Wait for the orders they
+// are processing to finish. The pool is paused once it returns, or still
+// draining if the wait elapsed first.
+func (u *orderUseCase) DrainWorkers(ctx context.Context, req dto.DrainWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error) {
+	if _, err := u.PauseWorkers(ctx, dto.ControlWorkersUsecaseRequest{Actor: req.Actor}); err != nil {
+		return dto.WorkerPoolUsecaseResponse{}, err
+	}
+
+	timeout := time.NewTimer(req.Wait)
+	defer timeout.Stop()
+
+	select {
+	case <-u.gate.idled():
+	case <-timeout.C:
+	case <-ctx.Done():
+		return dto.WorkerPoolUsecaseResponse{}, ctx.Err()
+	}
+	return u.GetWorkerPool(ctx)
+}
+
+// ResizeWorkers grows or shrinks the worker pool. New workers start claiming
+// orders right away; retired workers stop once their current order is done.
+func (u *orderUseCase) ResizeWorkers(ctx context.Context, req dto.ResizeWorkersUsecaseRequest) (res dto.WorkerPoolUsecaseResponse, err error) {
+	if req.Size < 0 || req.Size > maxWorkers {
+		return dto.WorkerPoolUsecaseResponse{}, pkg.ErrInvalidRequest.WithDescription(fmt.Sprintf("size must be between 0 and %d", maxWorkers))
+	}
+
+	previous := u.pool.resize(req.Size, req.Actor, req.Reason)
+	logger.Warn("Worker pool resized", "from", previous, "to", req.Size, "actor", req.Actor, "reason", req.Reason)
+	return u.GetWorkerPool(ctx)
+}
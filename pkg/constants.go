@@ -2,12 +2,52 @@ package pkg
 
 // service
 var (
-	StatusOrderManagementHigh   = "High"
-	StatusOrderManagementNormal = "Normal"
+	PriorityOrderManagementCritical = "Critical"
+	PriorityOrderManagementHigh     = "High"
+	PriorityOrderManagementNormal   = "Normal"
+	PriorityOrderManagementLow      = "Low"
+	PriorityOrderManagementBulk     = "Bulk"
 
 	StatusOrderManagementPending   = "Pending"
+	StatusOrderManagementScheduled = "Scheduled"
+	StatusOrderManagementRunning   = "Running"
 	StatusOrderManagementProcessed = "Processed"
 	StatusOrderManagementFailed    = "Failed"
+	StatusOrderManagementCancelled = "Cancelled"
+
+	StatusOrderManagementDeadLettered = "DeadLettered"
+
+	TopicOrderStatusChanged = "order.status_changed"
+
+	StatusBatchItemCreated   = "created"
+	StatusBatchItemDuplicate = "duplicate"
+	StatusBatchItemInvalid   = "invalid"
+
+	StatusWorkerPoolRunning  = "running"
+	StatusWorkerPoolDraining = "draining"
+	StatusWorkerPoolPaused   = "paused"
+
+	StatusWorkerIdle     = "idle"
+	StatusWorkerBusy     = "busy"
+	StatusWorkerRetiring = "retiring"
+
+	StatusWebhookDeliveryPending   = "Pending"
+	StatusWebhookDeliveryDelivered = "Delivered"
+	StatusWebhookDeliveryFailed    = "Failed"
+)
+
+// usecase
+var (
+	InvalidCursorUsecaseMessage          = "Error Usecase: Invalid cursor. The cursor is malformed or was issued for a different sort order."
+	IdempotencyKeyMismatchUsecaseMessage = "Error Usecase: Idempotency key reused. The key was already used for a different request."
+	InvalidTransitionUsecaseMessage      = "Error Usecase: Invalid status transition. The order lifecycle does not allow this status change."
+)
+
+// handler
+var (
+	InvalidRequestHandlerMessage      = "Error Handler: Invalid request. The request is malformed or one of its values is not valid."
+	BatchTooLargeHandlerMessage       = "Error Handler: Batch too large. The batch has more orders than a single request may carry."
+	InternalServerErrorHandlerMessage = "Error Handler: An unexpected issue has occurred. Please try again later."
 )
 
 // repository
@@ -17,4 +57,7 @@ var (
 	InternalServerErrorRepositoryMessage = "Error Repository: An unexpected issue has occurred. Please try again later."
 	DuplicateEntryRepositoryMessage      = "Error Repository: Duplicate entry. A record with this value already exists in the system."
 	RequiredFieldRepositoryMessage       = "Error Repository: required cannot be null. This field is required and must contain a valid value for the transaction to proceed."
+	StatusConflictRepositoryMessage      = "Error Repository: Status conflict. The order status changed while the request was handled."
+	LockNotHeldRepositoryMessage         = "Error Repository: Lock not held. The order is not locked by the requesting worker or its lease has expired."
+	UnavailableRepositoryMessage         = "Error Repository: Database busy. The database is locked by another writer; retry the request."
 )
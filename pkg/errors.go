@@ -5,12 +5,32 @@ import (
 	"strconv"
 )
 
+// ErrorCustom is an error of a known kind. Code is the HTTP status it maps to and
+// Type a stable, machine-readable name of its kind, shared by every error of the
+// kind regardless of its Message or Description.
 type ErrorCustom struct {
 	Code        int
+	Type        string
 	Message     string
 	Description string
 }
 
+// Error kinds. Compare errors to them with errors.Is, which matches any
+// *ErrorCustom of the same Type; use WithDescription to add details.
+var (
+	ErrNotFound             = &ErrorCustom{Code: 404, Type: "not_found", Message: NotFoundRepositoryMessage}
+	ErrDuplicateEntry       = &ErrorCustom{Code: 409, Type: "duplicate_entry", Message: DuplicateEntryRepositoryMessage}
+	ErrStatusConflict       = &ErrorCustom{Code: 409, Type: "status_conflict", Message: StatusConflictRepositoryMessage}
+	ErrLockNotHeld          = &ErrorCustom{Code: 409, Type: "lock_not_held", Message: LockNotHeldRepositoryMessage}
+	ErrInvalidTransition    = &ErrorCustom{Code: 409, Type: "invalid_transition", Message: InvalidTransitionUsecaseMessage}
+	ErrIdempotencyKeyReused = &ErrorCustom{Code: 422, Type: "idempotency_key_reused", Message: IdempotencyKeyMismatchUsecaseMessage}
+	ErrInvalidCursor        = &ErrorCustom{Code: 400, Type: "invalid_cursor", Message: InvalidCursorUsecaseMessage}
+	ErrInvalidRequest       = &ErrorCustom{Code: 400, Type: "invalid_request", Message: InvalidRequestHandlerMessage}
+	ErrBatchTooLarge        = &ErrorCustom{Code: 413, Type: "batch_too_large", Message: BatchTooLargeHandlerMessage}
+	ErrUnavailable          = &ErrorCustom{Code: 503, Type: "unavailable", Message: UnavailableRepositoryMessage}
+	ErrInternal             = &ErrorCustom{Code: 500, Type: "internal", Message: InternalServerErrorHandlerMessage}
+)
+
 // Error implements the error interface.
 func (e *ErrorCustom) Error() string {
 	var buffer bytes.Buffer
@@ -24,3 +44,34 @@ func (e *ErrorCustom) Error() string {
 
 	return buffer.String()
 }
+
+// Is reports whether target is an *ErrorCustom of the same kind.
+func (e *ErrorCustom) Is(target error) bool {
+	t, ok := target.(*ErrorCustom)
+	return ok && t.Type != "" && t.Type == e.Type
+}
+
+// WithDescription returns a copy of the error with the given description.
+func (e *ErrorCustom) WithDescription(description string) *ErrorCustom {
+	err := *e
+	err.Description = description
+	return &err
+}
+
+// StateTransitionError reports an order status change that the order lifecycle
+// does not allow. It is of the ErrInvalidTransition kind.
+type StateTransitionError struct {
+	OrderID string
+	From    string
+	To      string
+}
+
+// Error implements the error interface.
+func (e *StateTransitionError) Error() string {
+	return "invalid status transition of order " + e.OrderID + " from " + e.From + " to " + e.To
+}
+
+// Unwrap returns ErrInvalidTransition, the kind of the error.
+func (e *StateTransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"strconv"
+	"time"
+)
+
+// PriorityLevels maps the priority names accepted by the API to the level stored
+// in the repository. Orders with a higher level are served first.
+var PriorityLevels = map[string]int{
+	PriorityOrderManagementCritical: 50,
+	PriorityOrderManagementHigh:     40,
+	PriorityOrderManagementNormal:   30,
+	PriorityOrderManagementLow:      20,
+	PriorityOrderManagementBulk:     10,
+}
+
+// PriorityLevel returns the stored level of the named priority.
+func PriorityLevel(name string) (level int, ok bool) {
+	level, ok = PriorityLevels[name]
+	return
+}
+
+// PriorityName returns the name of a stored priority level. Levels without a
+// name are rendered as their number.
+func PriorityName(level int) string {
+	for name, l := range PriorityLevels {
+		if l == level {
+			return name
+		}
+	}
+	return strconv.Itoa(level)
+}
+
+// PriorityAging raises the effective priority of a waiting order by Step for
+// every full Interval it has waited, so that a steady stream of higher-level
+// orders cannot starve lower levels. A zero Interval disables aging.
+type PriorityAging struct {
+	Interval time.Duration
+	Step     int
+}
+
+// Effective returns the effective priority at now of an order with the given
+// level that has been waiting since waitingSince.
+func (a PriorityAging) Effective(level int, waitingSince, now time.Time) int {
+	if a.Interval <= 0 || a.Step <= 0 || !now.After(waitingSince) {
+		return level
+	}
+	return level + a.Step*int(now.Sub(waitingSince)/a.Interval)
+}
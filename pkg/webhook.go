@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Headers of a webhook request. The signature header holds "sha256=" followed by
+// SignWebhookPayload of the body and the timestamp header.
+var (
+	WebhookSignatureHeader  = "X-Webhook-Signature"
+	WebhookTimestampHeader  = "X-Webhook-Timestamp"
+	WebhookEventIDHeader    = "X-Webhook-Event-ID"
+	WebhookDeliveryIDHeader = "X-Webhook-Delivery-ID"
+)
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256, under secret, of
+// "<timestamp>.<body>" where timestamp is the Unix time the payload was sent at.
+// Signing the timestamp lets receivers reject replayed requests.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature, as sent in the signature
+// header, matches the body and timestamp.
+func VerifyWebhookSignature(secret string, timestamp int64, body []byte, signature string) bool {
+	expected := "sha256=" + SignWebhookPayload(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}